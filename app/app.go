@@ -1,20 +1,32 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore"
 	"github.com/bubulearn/bubucore/di"
 	"github.com/bubulearn/bubucore/ginsrv"
+	"github.com/bubulearn/bubucore/jobs"
+	"github.com/bubulearn/bubucore/metrics"
+	"github.com/bubulearn/bubucore/users"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const logTag = "[bubucore.App] "
 
 // NewApp creates new App instance.
-// If ctn is nil, GetDefaultDIBuilder() will be called.
+// If ctn is nil, the default container is built on the first access, see BuildDefaultContainer.
+// Commands run with Execute build only the default dependencies they need.
 func NewApp(ctn *di.Container) *App {
-	if ctn == nil {
-		ctn = BuildDefaultContainer()
-	}
 	return &App{
 		ctn: ctn,
 	}
@@ -26,14 +38,32 @@ type PrepareRouterFn func(router *gin.Engine, ctn *di.Container) error
 // PrepareContainerFn is a function to prepare DI container before run
 type PrepareContainerFn func(ctn *di.Container) error
 
+// ShutdownHookFn is a function to be called on the App's shutdown,
+// after the server stopped and before the DI container is closed
+type ShutdownHookFn func(ctx context.Context) error
+
 // App is a Bubulearn service app
 type App struct {
-	ctn *di.Container
+	ctn       *di.Container
+	srv       *http.Server
+	listeners []net.Listener
 
 	prepareCtnFn    PrepareContainerFn
 	prepareRouterFn PrepareRouterFn
+	shutdownHooks   []ShutdownHookFn
+	jobs            *jobs.Runner
+	routes          *ginsrv.Routes
+	commands        map[string]*Command
+	migrateFn       MigrateFn
+	migrateNeeds    []string
+	out             io.Writer
+	needs           []string
 
 	initialized bool
+	ctnPrepared bool
+	mu          sync.Mutex
+	closeOnce   sync.Once
+	reloadMu    sync.Mutex
 }
 
 // Init initializes App without starting the server
@@ -43,16 +73,36 @@ func (a *App) Init() {
 	}
 	a.initialized = true
 
-	if a.prepareCtnFn != nil {
-		err := a.prepareCtnFn(a.C())
-		if err != nil {
-			log.Fatal(logTag, "failed to prepare DI container: ", err)
+	a.prepareContainer()
+
+	for _, name := range []string{DITracing, DIJWTKeys} {
+		if a.C().Has(name) && a.needed(name) {
+			a.C().Get(name)
 		}
 	}
 
 	router := DIGetRouter(a.C())
 	router.Use(ginsrv.M().SetDIContainer(a.C()))
 
+	if !bubucore.Opt.DisableServiceRoutes {
+		health := &ginsrv.HealthController{}
+		health.SetContainer(a.C())
+		health.Init(router.Group(bubucore.Opt.APIBasePath))
+	}
+
+	if conf := DIGetConfig(a.C()); conf.MetricsEnable {
+		router.GET(conf.MetricsPath, gin.WrapH(metrics.Handler()))
+	}
+
+	if DIGetConfig(a.C()).AdminRoutes {
+		routes := a.Routes()
+		routes.Group(router.Group(bubucore.Opt.APIBasePath)).
+			Group("/admin").
+			JWTAccess().
+			RequireRoleHigher(users.RoleAdmin).
+			GET("/routes", routes.Handler(router))
+	}
+
 	if a.prepareRouterFn != nil {
 		err := a.prepareRouterFn(router, a.C())
 		if err != nil {
@@ -61,25 +111,239 @@ func (a *App) Init() {
 	}
 }
 
-// Run starts the App's server
+// needed checks if the definition is needed by the executed command, see Command.Needs.
+// All definitions are needed if the command does not declare them or no command is executed.
+func (a *App) needed(name string) bool {
+	if a.needs == nil {
+		return true
+	}
+	for _, n := range a.needs {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes returns the routes metadata registry, see DIDefRoutes.
+// Register routes with Routes().Group to record their metadata.
+// If the DIRoutes is not defined in the container, the App's own registry is used.
+func (a *App) Routes() *ginsrv.Routes {
+	if a.C().Has(DIRoutes) {
+		return DIGetRoutes(a.C())
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.routes == nil {
+		a.routes = ginsrv.NewRoutes()
+	}
+	return a.routes
+}
+
+// prepareContainer calls the prepare DI container hook once
+func (a *App) prepareContainer() {
+	if a.ctnPrepared {
+		return
+	}
+	a.ctnPrepared = true
+
+	if a.prepareCtnFn != nil {
+		err := a.prepareCtnFn(a.C())
+		if err != nil {
+			log.Fatal(logTag, "failed to prepare DI container: ", err)
+		}
+	}
+}
+
+// Run starts the App's server and blocks until SIGINT or SIGTERM is received.
+// If the PrintConfigFlag is set, prints the effective config and returns instead,
+// building only the Config of the default container, so no connections are required.
 func (a *App) Run() {
+	if PrintConfigRequested() {
+		err := a.buildContainer([]string{DIConfig})
+		if err == nil {
+			err = a.PrintConfig(os.Stdout)
+		}
+		a.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := a.Serve(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Serve starts the App's server on the Config.ListenAddrs and blocks until the ctx is done.
+// Then the server is gracefully shut down, see Shutdown.
+func (a *App) Serve(ctx context.Context) error {
 	a.Init()
-	defer a.Close()
 
-	router := DIGetRouter(a.ctn)
-	conf := DIGetConfig(a.ctn)
+	srv, err := a.initServer()
+	if err != nil {
+		a.Close()
+		return err
+	}
+
+	listeners := make([]net.Listener, 0)
+	for _, addr := range listenAddrs(DIGetConfig(a.C())) {
+		ln, err := listen(addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			a.Close()
+			return err
+		}
+		listeners = append(listeners, ln)
+	}
+
+	a.mu.Lock()
+	a.listeners = listeners
+	a.mu.Unlock()
+
+	a.startJobs()
 
-	log.Info(logTag, "starting gin server")
+	stopWatching := a.watchConfig()
+	defer stopWatching()
+
+	// http.Server sets up the TLSConfig on serve, so check it in advance
+	useTLS := srv.TLSConfig != nil
+
+	srvErr := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			var err error
+			if useTLS {
+				log.Info(logTag, "starting https server on ", ln.Addr().String())
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				log.Info(logTag, "starting http server on ", ln.Addr().String())
+				err = srv.Serve(ln)
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			srvErr <- err
+		}(ln)
+	}
 
-	err := router.Run(":" + conf.Port)
+	select {
+	case err = <-srvErr:
+		if err != nil {
+			log.Error(logTag, "http server failed: ", err)
+		}
+	case <-ctx.Done():
+		log.Info(logTag, "shutdown requested")
+	}
+
+	shutdownErr := a.Shutdown()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	return shutdownErr
+}
+
+// Addrs returns addresses the App's server listens on, empty until Serve is called
+func (a *App) Addrs() []net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	addrs := make([]net.Addr, len(a.listeners))
+	for i, ln := range a.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+// Shutdown stops accepting new connections, waits for in-flight requests, stops background jobs,
+// runs shutdown hooks and closes the App. Each phase is given its own Config.ShutdownTimeout,
+// so a slow drain does not leave the jobs and hooks with an expired context.
+func (a *App) Shutdown() error {
+	conf := DIGetConfig(a.C())
+
+	var err error
+	if a.srv != nil {
+		err = a.shutdownPhase(conf.ShutdownTimeout, a.srv.Shutdown)
+		if err != nil {
+			log.Error(logTag, "failed to drain http server: ", err)
+		}
+	}
+
+	if jobsErr := a.shutdownPhase(conf.ShutdownTimeout, a.jobsRunner().Stop); jobsErr != nil {
+		log.Error(logTag, "failed to stop jobs: ", jobsErr)
+	}
+
+	_ = a.shutdownPhase(conf.ShutdownTimeout, func(ctx context.Context) error {
+		for _, hook := range a.shutdownHooks {
+			if hookErr := hook(ctx); hookErr != nil {
+				log.Error(logTag, "shutdown hook failed: ", hookErr)
+			}
+		}
+		return nil
+	})
+
+	a.Close()
+
+	log.Info(logTag, "shutdown complete")
+
+	return err
+}
+
+// shutdownPhase calls fn with a context timing out after the timeout
+func (a *App) shutdownPhase(timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// PrintConfig writes the App's effective config with secrets redacted, see Config.WriteEnv
+func (a *App) PrintConfig(w io.Writer) error {
+	return DIGetConfig(a.C()).WriteEnv(w)
+}
+
+// PrintConfigRequested checks if the PrintConfigFlag is set in the bubucore.Options.ConfigFlags
+func PrintConfigRequested() bool {
+	if bubucore.Opt.ConfigFlags == nil {
+		return false
 	}
+	v, err := bubucore.Opt.ConfigFlags.GetBool(PrintConfigFlag)
+	return err == nil && v
 }
 
-// Close finalizes the App
+// Close finalizes the App. Safe to call multiple times.
 func (a *App) Close() {
-	a.ctn.Close()
+	a.closeOnce.Do(func() {
+		a.C().Close()
+	})
+}
+
+// Server returns the App's http.Server instance configured with the Config
+func (a *App) Server() *http.Server {
+	srv, err := a.initServer()
+	if err != nil {
+		log.Fatal(logTag, "failed to init http server: ", err)
+	}
+	return srv
+}
+
+// initServer creates the App's http.Server instance if not created yet
+func (a *App) initServer() (*http.Server, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.srv == nil {
+		srv, err := newServer(DIGetConfig(a.C()), DIGetRouter(a.C()))
+		if err != nil {
+			return nil, err
+		}
+		a.srv = srv
+	}
+	return a.srv, nil
 }
 
 // SetPrepareRouterFn sets init router hook
@@ -92,10 +356,64 @@ func (a *App) SetPrepareContainerFn(fn PrepareContainerFn) {
 	a.prepareCtnFn = fn
 }
 
+// AddShutdownHook adds a hook to be called on the App's shutdown.
+// Hooks are called in order of addition.
+func (a *App) AddShutdownHook(fn ShutdownHookFn) {
+	a.shutdownHooks = append(a.shutdownHooks, fn)
+}
+
+// AddWorker adds a long-running background worker started with the server, see jobs.Runner.AddWorker.
+// The worker's context is cancelled on shutdown.
+func (a *App) AddWorker(name string, fn jobs.Fn) {
+	a.jobsRunner().AddWorker(name, fn)
+}
+
+// Schedule adds a background job to run by the cron expression, see jobs.Runner.Schedule.
+// With the Config.JobsLeaderLock enabled, each job run is done by a single replica.
+func (a *App) Schedule(name string, cronExpr string, fn jobs.Fn) error {
+	return a.jobsRunner().Schedule(name, cronExpr, fn)
+}
+
+// jobsRunner returns the App's jobs.Runner instance
+func (a *App) jobsRunner() *jobs.Runner {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.jobs == nil {
+		a.jobs = jobs.NewRunner()
+	}
+	return a.jobs
+}
+
+// startJobs starts background jobs
+func (a *App) startJobs() {
+	runner := a.jobsRunner()
+	if DIGetConfig(a.C()).JobsLeaderLock {
+		runner.SetLocker(jobs.NewRedisLocker(DIGetRedis(a.C())))
+	}
+	runner.Start(context.Background())
+}
+
+// buildContainer builds the default container if the App has none.
+// Only the named definitions are built in advance if names is not nil, see di.Builder.BuildOnly.
+func (a *App) buildContainer(names []string) error {
+	if a.ctn != nil {
+		return nil
+	}
+	builder, err := GetDefaultDIBuilder()
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		a.ctn, err = builder.Build()
+	} else {
+		a.ctn, err = builder.BuildOnly(names...)
+	}
+	return err
+}
+
 // C returns an App's Container instance
 func (a *App) C() *di.Container {
 	if a.ctn == nil {
-		log.Warn(logTag, "nil Container given to the App, switching to default")
 		a.ctn = BuildDefaultContainer()
 	}
 	return a.ctn
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestApp_Serve(t *testing.T) {
+	var closed []string
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: DIConfig,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return &Config{Port: "0", ShutdownTimeout: time.Second}, nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, DIConfig)
+				return nil
+			},
+		},
+		di.Def{
+			Name: DIRouter,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				ctn.Get(DIConfig)
+				return gin.New(), nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, DIRouter)
+				return nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := NewApp(ctn)
+	a.AddWorker("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		closed = append(closed, "worker")
+		return nil
+	})
+	a.AddShutdownHook(func(ctx context.Context) error {
+		closed = append(closed, "hook")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Serve(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not stop")
+	}
+
+	assert.Equal(t, []string{"worker", "hook", DIRouter, DIConfig}, closed)
+
+	a.Close()
+	assert.Len(t, closed, 4)
+}
+
+func TestApp_ShutdownPhases(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name: DIConfig,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return &Config{ShutdownTimeout: 50 * time.Millisecond}, nil
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := NewApp(ctn)
+
+	release := make(chan struct{})
+	defer close(release)
+	a.AddWorker("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	var hookErr error
+	a.AddShutdownHook(func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		return nil
+	})
+
+	a.startJobs()
+
+	// the hooks get their own timeout after the jobs stop timed out
+	assert.NoError(t, a.Shutdown())
+	assert.NoError(t, hookErr)
+}
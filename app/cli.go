@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/spf13/pflag"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+// Default commands names
+const (
+	CommandServe   = "serve"
+	CommandMigrate = "migrate"
+	CommandConfig  = "config"
+	CommandRoutes  = "routes"
+	CommandHelp    = "help"
+)
+
+// CommandFn is a command function, the fs contains parsed command flags and positional args
+type CommandFn func(ctx context.Context, a *App, fs *pflag.FlagSet) error
+
+// MigrateFn is a migrations function called by the migrate command
+type MigrateFn func(ctx context.Context, ctn *di.Container) error
+
+// Command is an App's command-line subcommand, see App.Execute
+type Command struct {
+	// Name is a command name to call it with
+	Name string
+
+	// Usage is a short command description
+	Usage string
+
+	// Needs are the DI definitions names the command uses.
+	// With the default container, only these definitions and their dependencies are built before run,
+	// so the command does not initialize the router or connections it does not need.
+	// App.Init skips the tracing and JWT keys initialization unless they are declared.
+	// If nil, all non-lazy definitions are built.
+	Needs []string
+
+	// Flags defines the command flags, optional.
+	// The config flags are defined for all commands, see NewConfigFlagSet.
+	Flags func(fs *pflag.FlagSet)
+
+	// Run is a command function
+	Run CommandFn
+}
+
+// AddCommand registers the command. Overrides default command with the same name.
+func (a *App) AddCommand(cmd *Command) {
+	if a.commands == nil {
+		a.commands = make(map[string]*Command)
+	}
+	a.commands[cmd.Name] = cmd
+}
+
+// SetMigrateFn sets the migrate command function and the DI definitions it needs in addition to the Config
+func (a *App) SetMigrateFn(fn MigrateFn, needs ...string) {
+	a.migrateFn = fn
+	a.migrateNeeds = needs
+}
+
+// SetOutput sets the commands output writer, os.Stdout by default
+func (a *App) SetOutput(w io.Writer) {
+	a.out = w
+}
+
+// Output returns the commands output writer
+func (a *App) Output() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+	return a.out
+}
+
+// RunCLI executes the command from the process args and exits with non-zero code on failure,
+// see Execute
+func (a *App) RunCLI() {
+	err := a.Execute(os.Args[1:])
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Execute runs the command named by the first arg with the rest args as its flags.
+// Runs the serve command if args are empty or start with a flag.
+// The command context is cancelled on SIGINT or SIGTERM.
+func (a *App) Execute(args []string) error {
+	name := CommandServe
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := a.Commands()[name]
+	if !ok {
+		a.printUsage()
+		return errors.New(logTag + "unknown command `" + name + "`")
+	}
+
+	fs := NewConfigFlagSet()
+	fs.Init(name, pflag.ContinueOnError)
+	fs.SetOutput(a.Output())
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	bubucore.Opt.ConfigFlags = fs
+
+	needs := cmd.Needs
+	if cmd.Name == CommandServe && PrintConfigRequested() {
+		// the config is printed instead of serving
+		needs = []string{DIConfig}
+	}
+	a.needs = needs
+
+	err = a.buildContainer(needs)
+	if err != nil {
+		return err
+	}
+	a.prepareContainer()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return cmd.Run(ctx, a, fs)
+}
+
+// Commands returns the App's commands by names, including the default ones
+func (a *App) Commands() map[string]*Command {
+	cmds := map[string]*Command{
+		CommandServe: {
+			Name:  CommandServe,
+			Usage: "run the http server",
+			Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+				if PrintConfigRequested() {
+					defer a.Close()
+					return a.PrintConfig(a.Output())
+				}
+				return a.Serve(ctx)
+			},
+		},
+		CommandMigrate: {
+			Name:  CommandMigrate,
+			Usage: "run the migrations",
+			Needs: append([]string{DIConfig}, a.migrateNeeds...),
+			Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+				defer a.Close()
+				if a.migrateFn == nil {
+					return errors.New(logTag + "no migrations defined")
+				}
+				return a.migrateFn(ctx, a.C())
+			},
+		},
+		CommandConfig: {
+			Name:  CommandConfig,
+			Usage: "print the effective config with secrets redacted",
+			Needs: []string{DIConfig},
+			Flags: func(fs *pflag.FlagSet) {
+				fs.Bool("example", false, "print the .env.example file contents instead")
+				fs.Bool("markdown", false, "print the config keys Markdown reference instead")
+			},
+			Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+				defer a.Close()
+				if v, _ := fs.GetBool("example"); v {
+					return WriteConfigEnvExample(a.Output())
+				}
+				if v, _ := fs.GetBool("markdown"); v {
+					return WriteConfigMarkdown(a.Output())
+				}
+				return a.PrintConfig(a.Output())
+			},
+		},
+		CommandRoutes: {
+			Name:  CommandRoutes,
+			Usage: "print the http server routes",
+			Needs: []string{DIConfig, DIRouter, DIRoutes},
+			Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+				defer a.Close()
+				a.Init()
+				return a.printRoutes()
+			},
+		},
+		CommandHelp: {
+			Name:  CommandHelp,
+			Usage: "print the commands list",
+			Needs: []string{},
+			Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+				a.printUsage()
+				return nil
+			},
+		},
+	}
+	for name, cmd := range a.commands {
+		cmds[name] = cmd
+	}
+	return cmds
+}
+
+// printRoutes prints the router routes with their requirements, see Routes
+func (a *App) printRoutes() error {
+	w := tabwriter.NewWriter(a.Output(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "METHOD\tPATH\tAUTH\tROLES\tHANDLER")
+	for _, r := range a.Routes().List(DIGetRouter(a.C())) {
+		auth := r.Auth
+		if auth == "" {
+			auth = "-"
+		}
+		roles := strings.Join(r.Roles, " and ")
+		if roles == "" {
+			roles = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Path, auth, roles, r.Handler)
+	}
+	return w.Flush()
+}
+
+// printUsage prints the commands list
+func (a *App) printUsage() {
+	cmds := a.Commands()
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(a.Output(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", name, cmds[name].Usage)
+	}
+	_ = w.Flush()
+}
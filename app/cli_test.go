@@ -0,0 +1,140 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestApp_Execute(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+		bubucore.Opt.ConfigFlags = nil
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	// the default container is built partially, no connections are made
+	newApp := func() (*App, *bytes.Buffer) {
+		a := NewApp(nil)
+		out := &bytes.Buffer{}
+		a.SetOutput(out)
+		return a, out
+	}
+
+	a, out := newApp()
+	err := a.Execute([]string{CommandConfig, "--bubu-service-port=81"})
+	if assert.NoError(t, err) {
+		assert.Contains(t, out.String(), "\nBUBU_SERVICE_PORT=81\n")
+	}
+
+	a, out = newApp()
+	err = a.Execute([]string{CommandServe, "--print-config"})
+	if assert.NoError(t, err) {
+		assert.Contains(t, out.String(), "\nBUBU_SERVICE_PORT=80\n")
+	}
+
+	a, out = newApp()
+	err = a.Execute([]string{CommandConfig, "--markdown"})
+	if assert.NoError(t, err) {
+		assert.Contains(t, out.String(), "| `bubu_service_port` |")
+	}
+
+	a, out = newApp()
+	a.AddCommand(&Command{
+		Name:  "hello",
+		Usage: "says hello",
+		Needs: []string{DIConfig},
+		Flags: func(fs *pflag.FlagSet) {
+			fs.String("greeting", "Hello", "greeting")
+		},
+		Run: func(ctx context.Context, a *App, fs *pflag.FlagSet) error {
+			greeting, _ := fs.GetString("greeting")
+			_, err := a.Output().Write([]byte(greeting + ", " + fs.Arg(0) + " on " + DIGetConfig(a.C()).Port))
+			return err
+		},
+	})
+	err = a.Execute([]string{"hello", "--greeting=Hi", "world"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Hi, world on 80", out.String())
+	}
+
+	out.Reset()
+	assert.Error(t, a.Execute([]string{"unknown"}))
+	assert.Contains(t, out.String(), "hello")
+	assert.Contains(t, out.String(), CommandServe)
+
+	assert.Error(t, a.Execute([]string{CommandMigrate}))
+
+	migrated := false
+	a.SetMigrateFn(func(ctx context.Context, ctn *di.Container) error {
+		migrated = ctn.Has(DIMongo)
+		return nil
+	})
+	assert.NoError(t, a.Execute([]string{CommandMigrate}))
+	assert.True(t, migrated)
+}
+
+func TestApp_ExecuteRoutes(t *testing.T) {
+	defer func() {
+		bubucore.Opt.ConfigFlags = nil
+	}()
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: DIConfig,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return &Config{Port: "0", AdminRoutes: true}, nil
+			},
+		},
+		di.Def{
+			Name: DIRouter,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return gin.New(), nil
+			},
+		},
+		di.Def{
+			Name: DIJWTKeys,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				t.Error("JWT keys are not needed to print routes")
+				return nil, nil
+			},
+			Lazy: true,
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := NewApp(ctn)
+	a.SetPrepareRouterFn(func(router *gin.Engine, ctn *di.Container) error {
+		handler := func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		}
+		router.GET("/ping", handler)
+		a.Routes().Group(router.Group("/teachers")).JWTAccess().RequireRoleIn(500).GET("/:id", handler)
+		return nil
+	})
+
+	out := &bytes.Buffer{}
+	a.SetOutput(out)
+
+	err = a.Execute([]string{CommandRoutes})
+	if assert.NoError(t, err) {
+		assert.Regexp(t, `GET\s+/ping\s+-\s+-`, out.String())
+		assert.Regexp(t, `GET\s+/teachers/:id\s+required\s+in 500`, out.String())
+		assert.Regexp(t, `GET\s+/api/v1/admin/routes\s+required\s+>= 1000`, out.String())
+		assert.Contains(t, out.String(), "/health/ready")
+	}
+}
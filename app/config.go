@@ -2,64 +2,245 @@ package app
 
 import (
 	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/httpclient"
+	"github.com/bubulearn/bubucore/utils"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"net/http"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 // i18nFileDft is a default i18n file path
 const i18nFileDft = "./i18n.yml"
 
-// Config is a basic Bubulearn service config
+// shutdownTimeoutDft is a default graceful shutdown timeout
+const shutdownTimeoutDft = 15 * time.Second
+
+// Config is a basic Bubulearn service config.
+// The `config` tag defines the field's config key, the `default` tag defines its default value,
+// the `desc` tag describes the key, see ConfigDocs.
+// Fields with the `reload:"true"` tag are applied on the config reload, others require restart.
+// Fields with the `secret:"true"` tag may contain secret references resolved with SecretProvider,
+// e. g. `file:///run/secrets/jwt` or `env:JWT_PASSWORD`, their values are redacted in the Config dumps.
 type Config struct {
-	Port     string
-	LogLevel log.Level
+	Port     string    `config:"bubu_service_port" default:"80" desc:"Port to listen http(s) requests"`
+	LogLevel log.Level `config:"log_level" reload:"true" desc:"Log level, see logrus.ParseLevel()"`
+
+	// ShutdownTimeout is a time to wait for in-flight requests, then for jobs and for shutdown hooks each on shutdown
+	ShutdownTimeout time.Duration `config:"bubu_shutdown_timeout" default:"15" reload:"true" desc:"Seconds to wait for in-flight requests, then for jobs and for shutdown hooks each on shutdown"`
+
+	// ListenAddrs are TCP `host:port` or `unix:/path/to.sock` addresses to listen, `:Port` if empty
+	ListenAddrs []string `config:"bubu_listen" desc:"Comma-separated addresses to listen, TCP host:port or unix:/path/to.sock, :port if empty"`
+
+	TLSCertFile string `config:"bubu_tls_cert" desc:"Path to the TLS certificate file, enables TLS, reloaded on change"`
+	TLSKeyFile  string `config:"bubu_tls_key" desc:"Path to the TLS private key file, reloaded on change"`
+	H2C         bool   `config:"bubu_h2c" desc:"Serve HTTP/2 over cleartext connections"`
+
+	ReadTimeout       time.Duration `config:"bubu_read_timeout" default:"0" desc:"Seconds to read the whole request, 0 for no timeout"`
+	ReadHeaderTimeout time.Duration `config:"bubu_read_header_timeout" default:"10" desc:"Seconds to read the request headers, 0 for no timeout"`
+	WriteTimeout      time.Duration `config:"bubu_write_timeout" default:"0" desc:"Seconds to write the response, 0 for no timeout"`
+	IdleTimeout       time.Duration `config:"bubu_idle_timeout" default:"120" desc:"Seconds to keep idle keep-alive connections, 0 for no timeout"`
+	MaxHeaderBytes    int           `config:"bubu_max_header_bytes" default:"1048576" desc:"Maximum size of the request headers in bytes"`
+
+	// ConfigWatch enables the config file watching to reload the config on change
+	ConfigWatch bool `config:"bubu_config_watch" desc:"Reload config on the config file change"`
+
+	// AdminRoutes enables the routes list endpoint for admins
+	AdminRoutes bool `config:"bubu_admin_routes" desc:"Serve the routes list at the admin/routes endpoint, for admins only"`
+
+	// JobsLeaderLock enables Redis lock to run each scheduled job on a single replica
+	JobsLeaderLock bool `config:"bubu_jobs_leader_lock" desc:"Use Redis lock to run each scheduled job on a single replica"`
+
+	// MetricsEnable enables the unauthenticated metrics endpoint in the Prometheus text format, disabled by default
+	MetricsEnable bool   `config:"bubu_metrics_enable" default:"false" desc:"Serve metrics in the Prometheus text format without auth, keep the path private"`
+	MetricsPath   string `config:"bubu_metrics_path" default:"/metrics" desc:"Metrics endpoint path"`
 
-	CORSEnable    bool
-	CORSAllowAll  bool
-	CORSAllowCred bool
-	CORSAllowWS   bool
-	CORSAllowExt  bool
-	CORSMethods   []string
-	CORSHeaders   []string
-	CORSOrigins   []string
+	// TracingExport is a spans export target, see tracing.NewExporter
+	TracingExport string `config:"bubu_tracing_export" desc:"Spans export target: OTLP/HTTP collector URL, e. g. http://localhost:4318/v1/traces, or file:/path/to/spans.json; no export if empty"`
 
-	NotificationsHost  string
-	NotificationsToken string
+	CORSEnable    bool     `config:"cors_enable" reload:"true" desc:"Enable CORS headers"`
+	CORSAllowAll  bool     `config:"cors_allow_all" reload:"true" desc:"Allow all origins"`
+	CORSAllowCred bool     `config:"cors_allow_cred" reload:"true" desc:"Allow credentials"`
+	CORSAllowWS   bool     `config:"cors_allow_ws" reload:"true" desc:"Allow WebSocket schemes in origins"`
+	CORSAllowExt  bool     `config:"cors_allow_ext" reload:"true" desc:"Allow browser extensions schemes in origins"`
+	CORSMethods   []string `config:"cors_methods" reload:"true" desc:"Comma-separated allowed methods, GET, POST, PATCH, PUT and DELETE if empty"`
+	CORSHeaders   []string `config:"cors_headers" reload:"true" desc:"Comma-separated allowed headers"`
+	CORSOrigins   []string `config:"cors_origins" reload:"true" desc:"Comma-separated allowed origins, wildcards are allowed"`
 
-	UsersServiceHost     string
-	UsersServiceToken    string
-	UsersServiceUseRedis bool
-	UsersServiceTTL      int
+	NotificationsHost  string `config:"bubu_notifications_host" desc:"Bubulearn notifications service URL"`
+	NotificationsToken string `config:"bubu_notifications_token" secret:"true" desc:"Token for the Bubulearn notifications service"`
 
-	StaticServiceHost string
-	StaticServiceSign string
+	UsersServiceHost     string `config:"bubu_users_host" desc:"Bubulearn users service URL"`
+	UsersServiceToken    string `config:"bubu_users_token" secret:"true" desc:"Bubulearn users service JWT"`
+	UsersServiceUseRedis bool   `config:"bubu_users_use_redis" desc:"Use Redis cache for Bubulearn users service results"`
+	UsersServiceTTL      int    `config:"bubu_users_ttl" default:"3600" reload:"true" desc:"Seconds to cache Bubulearn users service results for"`
 
-	RedisHost     string
-	RedisDb       int
-	RedisPassword string
+	HTTPRetryAttempts    int           `config:"bubu_http_retry_attempts" default:"3" reload:"true" desc:"Maximum attempts of the services requests, 1 disables retries"`
+	HTTPBreakerThreshold int           `config:"bubu_http_breaker_threshold" default:"5" reload:"true" desc:"Consecutive services requests failures to open the circuit breaker, 0 disables it"`
+	HTTPBreakerTimeout   time.Duration `config:"bubu_http_breaker_timeout" default:"30" reload:"true" desc:"Seconds to fail the services requests fast while the circuit breaker is open"`
 
-	MongoHost     string
-	MongoUser     string
-	MongoPassword string
-	MongoDatabase string
+	StaticServiceHost string `config:"bubu_staticservice_host" desc:"Bubulearn static service URL"`
+	StaticServiceSign string `config:"bubu_staticservice_sign" secret:"true" desc:"Bubulearn static service sign"`
 
-	JWTPassword []byte
+	RedisHost     string `config:"redis_host" desc:"Redis host, e. g. localhost:6379"`
+	RedisDb       int    `config:"redis_db" default:"0" desc:"Redis database number"`
+	RedisPassword string `config:"redis_password" secret:"true" desc:"Redis password"`
+
+	MongoHost     string `config:"mongo_host" desc:"MongoDB host, e. g. localhost:27017"`
+	MongoUser     string `config:"mongo_username" desc:"MongoDB username"`
+	MongoPassword string `config:"mongo_password" secret:"true" desc:"MongoDB password"`
+	MongoDatabase string `config:"mongo_db" desc:"MongoDB database name"`
+
+	JWTPassword []byte `config:"bubu_jwt_password" reload:"true" secret:"true" desc:"JWT password"`
+
+	// JWTKeys are the key files to verify JWT with by the kid header instead of the JWTPassword,
+	// reloaded on every config reload to rotate keys, see tokens.LoadKeySet
+	JWTKeys []string `config:"bubu_jwt_keys" reload:"true" desc:"Comma-separated PEM or JWKS (.json) files to verify RS/ES/EdDSA JWT with, path, kid=path or kid:alg=path; HMAC with JWT password if empty"`
+
+	// JWKSURL is the auth service JWKS URL to verify JWT with instead of the JWTKeys files, see tokens.KeySource
+	JWKSURL string        `config:"bubu_jwks_url" reload:"true" desc:"Auth service JWKS URL to verify RS/ES/EdDSA JWT with, conflicts with JWT keys"`
+	JWKSTTL time.Duration `config:"bubu_jwks_ttl" default:"300" reload:"true" desc:"Seconds to cache JWKS keys for if the response has no Cache-Control max-age"`
+
+	I18nFile string `config:"i18n_file" desc:"Path to the i18n texts file, ./i18n.yml if exists"`
+
+	// sources contains layers the fields values are loaded from by field names
+	sources map[string]bubucore.ConfigLayer
+
+	// problems contains values parsing problems to be reported by Validate
+	problems []*ConfigProblem
+
+	// subs contains change subscribers, shared by the reloaded Config instances
+	subs *configSubs
+}
 
-	I18nFile string
+// ConfigKeys returns config keys by Config field names, see the `config` tag
+func ConfigKeys() map[string]string {
+	keys := make(map[string]string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("config"); key != "" {
+			keys[t.Field(i).Name] = key
+		}
+	}
+	return keys
+}
+
+// ConfigDefaults returns config default values by config keys, see the `default` tag
+func ConfigDefaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("config")
+		val, ok := t.Field(i).Tag.Lookup("default")
+		if key != "" && ok {
+			defaults[key] = val
+		}
+	}
+	return defaults
+}
+
+// NewConfigFlagSet creates command-line flags set with a flag for each config key,
+// e. g. --bubu-service-port for the bubu_service_port key, and the PrintConfigFlag
+func NewConfigFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	keys := ConfigKeys()
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		fs.String(bubucore.ConfigKeyFlag(key), "", "overrides the "+key+" config value")
+	}
+	fs.Bool(PrintConfigFlag, false, "print the effective config with secrets redacted and exit")
+	return fs
+}
+
+// ParseConfigFlags parses command-line args and sets them as the config flags layer,
+// see bubucore.LoadConfig
+func ParseConfigFlags(args []string) error {
+	fs := NewConfigFlagSet()
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	bubucore.Opt.ConfigFlags = fs
+	return nil
+}
+
+// Source returns the layer the Config field value is loaded from
+func (c *Config) Source(field string) bubucore.ConfigLayer {
+	if src, ok := c.sources[field]; ok {
+		return src
+	}
+	return bubucore.ConfigLayerDefault
 }
 
 // SetFromViper applies values from the viper config to the Config instance
+// and applies them to global instances, see ApplyToGlobals
 func (c *Config) SetFromViper(conf *viper.Viper) {
-	logLvl, err := log.ParseLevel(conf.GetString("log_level"))
-	if err != nil {
-		logLvl = bubucore.Opt.LogLevelDft
+	c.setFromViper(conf)
+	c.ApplyToGlobals()
+}
+
+// setFromViper applies values from the viper config to the Config instance
+func (c *Config) setFromViper(conf *viper.Viper) {
+	for key, val := range ConfigDefaults() {
+		conf.SetDefault(key, val)
+	}
+
+	c.sources = make(map[string]bubucore.ConfigLayer)
+	for field, key := range ConfigKeys() {
+		c.sources[field] = bubucore.ConfigLayerOf(conf, key)
+	}
+
+	c.problems = nil
+
+	logLvl := bubucore.Opt.LogLevelDft
+	if raw := conf.GetString("log_level"); raw != "" {
+		lvl, err := log.ParseLevel(raw)
+		if err != nil {
+			c.addProblem("LogLevel", "log_level", "unknown log level `"+raw+"`")
+		} else {
+			logLvl = lvl
+		}
 	}
 
 	c.Port = conf.GetString("bubu_service_port")
 	c.LogLevel = logLvl
+	c.ConfigWatch = conf.GetBool("bubu_config_watch")
+	c.AdminRoutes = conf.GetBool("bubu_admin_routes")
+	c.JobsLeaderLock = conf.GetBool("bubu_jobs_leader_lock")
+	c.MetricsEnable = conf.GetBool("bubu_metrics_enable")
+	c.MetricsPath = conf.GetString("bubu_metrics_path")
+	c.TracingExport = conf.GetString("bubu_tracing_export")
+
+	// server
+	{
+		values := strings.TrimSpace(conf.GetString("bubu_listen"))
+		c.ListenAddrs = utils.FilterStrings(strings.Split(values, ","))
+
+		c.TLSCertFile = conf.GetString("bubu_tls_cert")
+		c.TLSKeyFile = conf.GetString("bubu_tls_key")
+		c.H2C = conf.GetBool("bubu_h2c")
+
+		c.ReadTimeout = time.Duration(c.getInt(conf, "ReadTimeout")) * time.Second
+		c.ReadHeaderTimeout = time.Duration(c.getInt(conf, "ReadHeaderTimeout")) * time.Second
+		c.WriteTimeout = time.Duration(c.getInt(conf, "WriteTimeout")) * time.Second
+		c.IdleTimeout = time.Duration(c.getInt(conf, "IdleTimeout")) * time.Second
+		c.MaxHeaderBytes = c.getInt(conf, "MaxHeaderBytes")
+	}
+
+	c.ShutdownTimeout = time.Duration(c.getInt(conf, "ShutdownTimeout")) * time.Second
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = shutdownTimeoutDft
+	}
 
 	// CORS
 	{
@@ -77,33 +258,43 @@ func (c *Config) SetFromViper(conf *viper.Viper) {
 		}
 
 		values = strings.TrimSpace(conf.GetString("cors_headers"))
-		c.CORSHeaders = strings.Split(values, ",")
+		c.CORSHeaders = utils.FilterStrings(strings.Split(values, ","))
 
 		values = strings.TrimSpace(conf.GetString("cors_origins"))
-		c.CORSOrigins = strings.Split(values, ",")
+		c.CORSOrigins = utils.FilterStrings(strings.Split(values, ","))
 	}
 
 	c.NotificationsHost = conf.GetString("bubu_notifications_host")
-	c.NotificationsToken = conf.GetString("bubu_notifications_token")
+	c.NotificationsToken = c.getSecret(conf, "NotificationsToken")
 
 	c.UsersServiceHost = conf.GetString("bubu_users_host")
-	c.UsersServiceToken = conf.GetString("bubu_users_token")
+	c.UsersServiceToken = c.getSecret(conf, "UsersServiceToken")
 	c.UsersServiceUseRedis = conf.GetBool("bubu_users_use_redis")
-	c.UsersServiceTTL = conf.GetInt("bubu_users_ttl")
+	c.UsersServiceTTL = c.getInt(conf, "UsersServiceTTL")
+
+	c.HTTPRetryAttempts = c.getInt(conf, "HTTPRetryAttempts")
+	c.HTTPBreakerThreshold = c.getInt(conf, "HTTPBreakerThreshold")
+	c.HTTPBreakerTimeout = time.Duration(c.getInt(conf, "HTTPBreakerTimeout")) * time.Second
 
 	c.StaticServiceHost = conf.GetString("bubu_staticservice_host")
-	c.StaticServiceSign = conf.GetString("bubu_staticservice_sign")
+	c.StaticServiceSign = c.getSecret(conf, "StaticServiceSign")
 
 	c.RedisHost = conf.GetString("redis_host")
-	c.RedisDb = conf.GetInt("redis_db")
-	c.RedisPassword = conf.GetString("redis_password")
+	c.RedisDb = c.getInt(conf, "RedisDb")
+	c.RedisPassword = c.getSecret(conf, "RedisPassword")
 
 	c.MongoHost = conf.GetString("mongo_host")
 	c.MongoUser = conf.GetString("mongo_username")
-	c.MongoPassword = conf.GetString("mongo_password")
+	c.MongoPassword = c.getSecret(conf, "MongoPassword")
 	c.MongoDatabase = conf.GetString("mongo_db")
 
-	c.JWTPassword = []byte(conf.GetString("bubu_jwt_password"))
+	c.JWTPassword = []byte(c.getSecret(conf, "JWTPassword"))
+	{
+		values := strings.TrimSpace(conf.GetString("bubu_jwt_keys"))
+		c.JWTKeys = utils.FilterStrings(strings.Split(values, ","))
+	}
+	c.JWKSURL = conf.GetString("bubu_jwks_url")
+	c.JWKSTTL = time.Duration(c.getInt(conf, "JWKSTTL")) * time.Second
 
 	c.I18nFile = conf.GetString("i18n_file")
 	if c.I18nFile == "" {
@@ -111,12 +302,45 @@ func (c *Config) SetFromViper(conf *viper.Viper) {
 			c.I18nFile = i18nFileDft
 		}
 	}
+}
 
-	c.ApplyToGlobals()
+// getInt reads integer value of the field's config key, reports unparseable values
+func (c *Config) getInt(conf *viper.Viper, field string) int {
+	key := ConfigKeys()[field]
+	v, err := cast.ToIntE(conf.Get(key))
+	if err != nil {
+		c.addProblem(field, key, "integer value expected, got `"+conf.GetString(key)+"`")
+	}
+	return v
+}
+
+// getSecret reads the field's config value resolving secret reference, reports unresolved ones
+func (c *Config) getSecret(conf *viper.Viper, field string) string {
+	key := ConfigKeys()[field]
+	v, err := ResolveSecret(conf.GetString(key))
+	if err != nil {
+		c.addProblem(field, key, "failed to resolve secret: "+err.Error())
+	}
+	return v
+}
+
+// RetryPolicy returns the services clients httpclient.RetryPolicy
+func (c *Config) RetryPolicy() httpclient.RetryPolicy {
+	policy := httpclient.DefaultRetryPolicy()
+	policy.MaxAttempts = c.HTTPRetryAttempts
+	return policy
+}
+
+// BreakerPolicy returns the services clients httpclient.BreakerPolicy
+func (c *Config) BreakerPolicy() httpclient.BreakerPolicy {
+	return httpclient.BreakerPolicy{
+		FailureThreshold: c.HTTPBreakerThreshold,
+		OpenTimeout:      c.HTTPBreakerTimeout,
+	}
 }
 
 // ApplyToGlobals applies values from the Config instance to global instances
 func (c *Config) ApplyToGlobals() {
 	log.SetLevel(c.LogLevel)
-	bubucore.Opt.JWTPassword = c.JWTPassword
+	bubucore.Opt.SetJWTPassword(c.JWTPassword)
 }
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/bubulearn/bubucore"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrintConfigFlag is a command-line flag to print the effective config and exit, see App.Run
+const PrintConfigFlag = "print-config"
+
+// ConfigKeyDoc describes a config key, see the Config tags
+type ConfigKeyDoc struct {
+	// Field is a Config field name
+	Field string `json:"field"`
+
+	// Key is a config key
+	Key string `json:"key"`
+
+	// Env is an environment variable name of the key
+	Env string `json:"env"`
+
+	// Flag is a command-line flag name of the key
+	Flag string `json:"flag"`
+
+	// Default is a default value of the key
+	Default string `json:"default"`
+
+	// Description is a human-readable key description
+	Description string `json:"description"`
+
+	// Secret tells if the key value is a secret
+	Secret bool `json:"secret"`
+
+	// Reload tells if the key value is applied on the config reload without restart
+	Reload bool `json:"reload"`
+}
+
+// ConfigDocs returns config keys descriptions in the Config fields order
+func ConfigDocs() []*ConfigKeyDoc {
+	docs := make([]*ConfigKeyDoc, 0)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		key := tag.Get("config")
+		if key == "" {
+			continue
+		}
+		docs = append(docs, &ConfigKeyDoc{
+			Field:       t.Field(i).Name,
+			Key:         key,
+			Env:         bubucore.ConfigKeyEnv(key),
+			Flag:        "--" + bubucore.ConfigKeyFlag(key),
+			Default:     tag.Get("default"),
+			Description: tag.Get("desc"),
+			Secret:      tag.Get("secret") == "true",
+			Reload:      tag.Get("reload") == "true",
+		})
+	}
+	return docs
+}
+
+// WriteConfigEnvExample writes `.env.example` file contents with all config keys and their defaults
+func WriteConfigEnvExample(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, doc := range ConfigDocs() {
+		if i > 0 {
+			_, _ = bw.WriteString("\n")
+		}
+		writeEnvComment(bw, doc, "")
+		_, _ = bw.WriteString(doc.Env + "=" + doc.Default + "\n")
+	}
+	return bw.Flush()
+}
+
+// WriteConfigMarkdown writes Markdown reference of the config keys
+func WriteConfigMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	_, _ = bw.WriteString("| Key | Env | Flag | Default | Description |\n")
+	_, _ = bw.WriteString("|-----|-----|------|---------|-------------|\n")
+
+	for _, doc := range ConfigDocs() {
+		desc := doc.Description
+		if doc.Secret {
+			desc += ". Secret, accepts `file://` and `env:` references"
+		}
+		if !doc.Reload {
+			desc += ". Requires restart to apply"
+		}
+
+		def := ""
+		if doc.Default != "" {
+			def = "`" + doc.Default + "`"
+		}
+
+		_, _ = fmt.Fprintf(bw, "| `%s` | `%s` | `%s` | %s | %s |\n",
+			doc.Key, doc.Env, doc.Flag, def, strings.ReplaceAll(desc, "|", "\\|"))
+	}
+
+	return bw.Flush()
+}
+
+// WriteEnv writes the Config values in the `.env` format with secrets redacted
+// and the value sources in comments
+func (c *Config) WriteEnv(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	v := reflect.ValueOf(c).Elem()
+	for i, doc := range ConfigDocs() {
+		if i > 0 {
+			_, _ = bw.WriteString("\n")
+		}
+		writeEnvComment(bw, doc, string(c.Source(doc.Field)))
+
+		val := formatConfigValue(v.FieldByName(doc.Field))
+		if doc.Secret && val != "" {
+			val = secretRedacted
+		}
+		_, _ = bw.WriteString(doc.Env + "=" + val + "\n")
+	}
+	return bw.Flush()
+}
+
+// writeEnvComment writes the key description comment
+func writeEnvComment(w *bufio.Writer, doc *ConfigKeyDoc, source string) {
+	if doc.Description != "" {
+		_, _ = w.WriteString("# " + doc.Description + "\n")
+	}
+
+	notes := make([]string, 0, 3)
+	if source != "" {
+		notes = append(notes, "source: "+source)
+	}
+	if doc.Secret {
+		notes = append(notes, "secret, accepts file:// and env: references")
+	}
+	if !doc.Reload {
+		notes = append(notes, "requires restart")
+	}
+	if len(notes) == 0 {
+		return
+	}
+	_, _ = w.WriteString("# (" + strings.Join(notes, "; ") + ")\n")
+}
+
+// formatConfigValue formats the Config field value the way it is read from the config
+func formatConfigValue(v reflect.Value) string {
+	switch val := v.Interface().(type) {
+	case []string:
+		return strings.Join(val, ",")
+	case []byte:
+		return string(val)
+	case time.Duration:
+		return strconv.FormatInt(int64(val/time.Second), 10)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
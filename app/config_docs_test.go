@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bytes"
+	"github.com/bubulearn/bubucore"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestConfigDocs(t *testing.T) {
+	docs := ConfigDocs()
+	if !assert.Len(t, docs, len(ConfigKeys())) {
+		return
+	}
+
+	port := docs[0]
+	assert.Equal(t, "Port", port.Field)
+	assert.Equal(t, "bubu_service_port", port.Key)
+	assert.Equal(t, "BUBU_SERVICE_PORT", port.Env)
+	assert.Equal(t, "--bubu-service-port", port.Flag)
+	assert.Equal(t, "80", port.Default)
+	assert.False(t, port.Reload)
+	assert.False(t, port.Secret)
+
+	for _, doc := range docs {
+		assert.NotEmpty(t, doc.Description, doc.Key)
+		assert.Equal(t, IsSecret(doc.Field), doc.Secret, doc.Key)
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteConfigEnvExample(buf)
+	if assert.NoError(t, err) {
+		assert.Contains(t, buf.String(), "# Port to listen http(s) requests\n# (requires restart)\nBUBU_SERVICE_PORT=80\n")
+		assert.Contains(t, buf.String(), "\nBUBU_JWT_PASSWORD=\n")
+		assert.Contains(t, buf.String(), "# Log level, see logrus.ParseLevel()\nLOG_LEVEL=")
+		assert.NotContains(t, buf.String(), "# ()")
+	}
+
+	buf.Reset()
+	err = WriteConfigMarkdown(buf)
+	if assert.NoError(t, err) {
+		assert.Contains(t, buf.String(), "| `bubu_users_ttl` | `BUBU_USERS_TTL` | `--bubu-users-ttl` | `3600` |")
+		assert.Equal(t, len(docs)+2, strings.Count(buf.String(), "\n"))
+	}
+}
+
+func TestApp_PrintConfig(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+		bubucore.Opt.ConfigFlags = nil
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	assert.False(t, PrintConfigRequested())
+	err := ParseConfigFlags([]string{"--print-config", "--cors-methods=GET,POST"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, PrintConfigRequested())
+
+	v, err := bubucore.LoadConfig(ConfigDefaults())
+	if !assert.NoError(t, err) {
+		return
+	}
+	conf := &Config{}
+	conf.setFromViper(v)
+
+	buf := &bytes.Buffer{}
+	err = conf.WriteEnv(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	out := buf.String()
+	assert.Contains(t, out, "# (source: file; requires restart)\nBUBU_SERVICE_PORT=80\n")
+	assert.Contains(t, out, "# (source: flag)\nCORS_METHODS=GET,POST\n")
+	assert.Contains(t, out, "\nBUBU_SHUTDOWN_TIMEOUT=5\n")
+	assert.Contains(t, out, "\nBUBU_JWT_PASSWORD="+secretRedacted+"\n")
+	assert.Contains(t, out, "\nBUBU_USERS_TOKEN=\n")
+	assert.NotContains(t, out, "12345")
+}
+
+func TestApp_RunPrintConfig(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+		bubucore.Opt.ConfigFlags = nil
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	err := ParseConfigFlags([]string{"--print-config"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// only the Config is built, the unavailable connections do not fail the print
+	a := NewApp(nil)
+	a.Run()
+	assert.Equal(t, "80", DIGetConfig(a.C()).Port)
+}
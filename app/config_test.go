@@ -5,6 +5,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestConfig_SetFromViper(t *testing.T) {
@@ -18,15 +19,155 @@ func TestConfig_SetFromViper(t *testing.T) {
 	conf.SetFromViper(v)
 
 	assert.Equal(t, "80", conf.Port)
+	assert.Equal(t, 5*time.Second, conf.ShutdownTimeout)
 
 	assert.Equal(t, "http://localhost/notifications", conf.NotificationsHost)
 	assert.Equal(t, "notifications-token", conf.NotificationsToken)
 
 	assert.Equal(t, "localhost:6379", conf.RedisHost)
 	assert.Equal(t, "localhost:27017", conf.MongoHost)
+	assert.False(t, conf.MetricsEnable)
 
 	conf.ApplyToGlobals()
 
 	assert.Equal(t, []byte("12345"), bubucore.Opt.JWTPassword)
 	assert.Equal(t, log.InfoLevel, log.GetLevel())
 }
+
+func TestConfig_Layers(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	initialPrefix := bubucore.Opt.ConfigEnvPrefix
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+		bubucore.Opt.ConfigEnvPrefix = initialPrefix
+		bubucore.Opt.ConfigFlags = nil
+	}()
+
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+	bubucore.Opt.ConfigEnvPrefix = "BUBUTEST"
+
+	t.Setenv("BUBUTEST_REDIS_HOST", "redis.env:6379")
+	t.Setenv("BUBUTEST_MONGO_HOST", "mongo.env:27017")
+
+	err := ParseConfigFlags([]string{"--mongo-host=mongo.flag:27017"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	v, err := bubucore.LoadConfig(ConfigDefaults())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf := &Config{}
+	conf.SetFromViper(v)
+
+	assert.Equal(t, "80", conf.Port)
+	assert.Equal(t, bubucore.ConfigLayerFile, conf.Source("Port"))
+
+	assert.Equal(t, "redis.env:6379", conf.RedisHost)
+	assert.Equal(t, bubucore.ConfigLayerEnv, conf.Source("RedisHost"))
+
+	assert.Equal(t, "mongo.flag:27017", conf.MongoHost)
+	assert.Equal(t, bubucore.ConfigLayerFlag, conf.Source("MongoHost"))
+
+	assert.Equal(t, 0, conf.RedisDb)
+	assert.Equal(t, bubucore.ConfigLayerFile, conf.Source("RedisDb"))
+
+	assert.Equal(t, bubucore.ConfigLayerDefault, conf.Source("CORSEnable"))
+	assert.Equal(t, bubucore.ConfigLayerDefault, conf.Source("UnknownField"))
+}
+
+func TestConfig_EnvOnly(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+	}()
+
+	bubucore.Opt.ConfigFilePath = "__unknown_file__.env"
+
+	t.Setenv("BUBU_SERVICE_PORT", "8080")
+
+	v, err := bubucore.LoadConfig(ConfigDefaults())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf := &Config{}
+	conf.SetFromViper(v)
+
+	assert.Equal(t, "8080", conf.Port)
+	assert.Equal(t, bubucore.ConfigLayerEnv, conf.Source("Port"))
+
+	assert.Equal(t, 3600, conf.UsersServiceTTL)
+	assert.Equal(t, 15*time.Second, conf.ShutdownTimeout)
+	assert.Equal(t, bubucore.ConfigLayerDefault, conf.Source("UsersServiceTTL"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+	v, err := bubucore.ReadConfig()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf := &Config{}
+	conf.SetFromViper(v)
+	assert.NoError(t, conf.Validate())
+
+	v.Set("bubu_service_port", "")
+	v.Set("log_level", "loud")
+	v.Set("bubu_users_ttl", "hour")
+	v.Set("bubu_users_host", "localhost:8801")
+	v.Set("redis_host", "")
+	v.Set("mongo_username", "root")
+	v.Set("cors_enable", true)
+	v.Set("cors_allow_all", true)
+	v.Set("cors_allow_cred", true)
+	v.Set("cors_origins", "example.com")
+
+	conf = &Config{}
+	conf.SetFromViper(v)
+
+	err = conf.Validate()
+	confErr := &ConfigError{}
+	if !assert.ErrorAs(t, err, &confErr) {
+		return
+	}
+
+	keys := make([]string, 0)
+	for _, p := range confErr.Problems {
+		keys = append(keys, p.Key)
+	}
+	assert.ElementsMatch(t, []string{
+		"log_level",
+		"bubu_users_ttl",
+		"bubu_service_port",
+		"bubu_users_host",
+		"redis_host",
+		"mongo_password",
+		"cors_origins",
+		"cors_allow_cred",
+		"cors_origins",
+	}, keys)
+	assert.Contains(t, err.Error(), "9 problem(s) found")
+	assert.Contains(t, err.Error(), "redis_host: redis host is required when users service caching is enabled")
+
+	conf = &Config{Port: "70000", CORSEnable: true}
+	err = conf.Validate()
+	if assert.ErrorAs(t, err, &confErr) {
+		assert.Len(t, confErr.Problems, 3)
+	}
+
+	conf = &Config{JWTKeys: []string{"rsa.pem", "rsa-1=rsa.pem", "rsa-2:PS256=rsa.pem", ":PS384=rsa.pem", "=rsa.pem", "rsa-3="}}
+	err = conf.Validate()
+	if assert.ErrorAs(t, err, &confErr) {
+		invalid := 0
+		for _, p := range confErr.Problems {
+			if p.Field == "JWTKeys" {
+				invalid++
+			}
+		}
+		assert.Equal(t, 2, invalid)
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"github.com/bubulearn/bubucore/tokens"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigProblem is a single Config validation problem
+type ConfigProblem struct {
+	// Field is a Config field name
+	Field string `json:"field"`
+
+	// Key is a config key
+	Key string `json:"key"`
+
+	// Message is a human-readable problem description
+	Message string `json:"message"`
+}
+
+// String returns problem as a string
+func (p *ConfigProblem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// ConfigError is an aggregated Config validation error
+type ConfigError struct {
+	Problems []*ConfigProblem `json:"problems"`
+}
+
+// Error as a string
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  - " + p.String()
+	}
+	return logTag + "invalid config, " + strconv.Itoa(len(e.Problems)) + " problem(s) found:\n" + strings.Join(lines, "\n")
+}
+
+// Validate checks the Config values and their consistency.
+// Returns ConfigError with all problems found, or nil if the Config is valid.
+func (c *Config) Validate() error {
+	v := &configValidator{
+		problems: append([]*ConfigProblem{}, c.problems...),
+	}
+
+	// port
+	if c.Port == "" {
+		v.add("Port", "port is required")
+	} else if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		v.add("Port", "port must be a number in range 1-65535, got `"+c.Port+"`")
+	}
+
+	// server
+	for _, addr := range c.ListenAddrs {
+		if path, ok := unixSocketPath(addr); ok {
+			if path == "" {
+				v.add("ListenAddrs", "unix socket path is required, got `"+addr+"`")
+			}
+		} else if _, _, err := net.SplitHostPort(addr); err != nil {
+			v.add("ListenAddrs", "host:port or unix:/path address expected, got `"+addr+"`")
+		}
+	}
+	if c.TLSCertFile != "" && c.TLSKeyFile == "" {
+		v.add("TLSKeyFile", "TLS key file is required when TLS certificate file is defined")
+	}
+	if c.TLSCertFile == "" && c.TLSKeyFile != "" {
+		v.add("TLSCertFile", "TLS certificate file is required when TLS key file is defined")
+	}
+	timeouts := []struct {
+		field string
+		val   time.Duration
+	}{
+		{"ReadTimeout", c.ReadTimeout},
+		{"ReadHeaderTimeout", c.ReadHeaderTimeout},
+		{"WriteTimeout", c.WriteTimeout},
+		{"IdleTimeout", c.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.val < 0 {
+			v.add(t.field, "timeout must not be negative")
+		}
+	}
+	if c.MaxHeaderBytes < 0 {
+		v.add("MaxHeaderBytes", "max header bytes must not be negative")
+	}
+
+	// metrics
+	if c.MetricsEnable && !strings.HasPrefix(c.MetricsPath, "/") {
+		v.add("MetricsPath", "absolute path expected, got `"+c.MetricsPath+"`")
+	}
+
+	// tracing
+	if strings.HasPrefix(c.TracingExport, "file:") {
+		if strings.TrimPrefix(c.TracingExport, "file:") == "" {
+			v.add("TracingExport", "file path is required, got `"+c.TracingExport+"`")
+		}
+	} else {
+		v.url("TracingExport", c.TracingExport)
+	}
+
+	// services URLs
+	v.url("NotificationsHost", c.NotificationsHost)
+	v.url("UsersServiceHost", c.UsersServiceHost)
+	v.url("StaticServiceHost", c.StaticServiceHost)
+
+	// services clients
+	if c.HTTPRetryAttempts < 0 {
+		v.add("HTTPRetryAttempts", "retry attempts must not be negative")
+	}
+	if c.HTTPBreakerThreshold < 0 {
+		v.add("HTTPBreakerThreshold", "circuit breaker threshold must not be negative")
+	}
+	if c.HTTPBreakerTimeout < 0 {
+		v.add("HTTPBreakerTimeout", "timeout must not be negative")
+	}
+
+	// JWT keys
+	for _, file := range c.JWTKeys {
+		kid, alg, path := tokens.ParseKeySpec(file)
+		if path == "" || (path != file && kid == "" && alg == "") {
+			v.add("JWTKeys", "path, kid=path or kid:alg=path expected, got `"+file+"`")
+		}
+	}
+	v.url("JWKSURL", c.JWKSURL)
+	if c.JWKSURL != "" && len(c.JWTKeys) > 0 {
+		v.add("JWKSURL", "JWKS URL conflicts with JWT keys files")
+	}
+	if c.JWKSTTL < 0 {
+		v.add("JWKSTTL", "cache TTL must not be negative")
+	}
+
+	// users service
+	if c.UsersServiceUseRedis && c.RedisHost == "" {
+		v.add("RedisHost", "redis host is required when users service caching is enabled")
+	}
+	if c.JobsLeaderLock && c.RedisHost == "" {
+		v.add("RedisHost", "redis host is required when jobs leader lock is enabled")
+	}
+	if c.UsersServiceTTL < 0 {
+		v.add("UsersServiceTTL", "cache TTL must not be negative")
+	}
+
+	// redis
+	if c.RedisDb < 0 {
+		v.add("RedisDb", "redis db must not be negative")
+	}
+
+	// mongo
+	if c.MongoHost != "" && c.MongoDatabase == "" {
+		v.add("MongoDatabase", "mongo database is required when mongo host is defined")
+	}
+	if c.MongoUser != "" && c.MongoPassword == "" {
+		v.add("MongoPassword", "mongo password is required when mongo username is defined")
+	}
+	if c.MongoUser == "" && c.MongoPassword != "" {
+		v.add("MongoUser", "mongo username is required when mongo password is defined")
+	}
+
+	// CORS
+	if c.CORSEnable {
+		if c.CORSAllowAll {
+			if len(c.CORSOrigins) > 0 {
+				v.add("CORSOrigins", "origins list conflicts with allowing all origins")
+			}
+			if c.CORSAllowCred {
+				v.add("CORSAllowCred", "credentials can not be allowed for all origins")
+			}
+		} else if len(c.CORSOrigins) == 0 {
+			v.add("CORSOrigins", "origins list is required when not all origins are allowed")
+		}
+		for _, origin := range c.CORSOrigins {
+			if !strings.Contains(origin, "*") && !strings.Contains(origin, "://") {
+				v.add("CORSOrigins", "origin must contain a scheme or a wildcard, got `"+origin+"`")
+			}
+		}
+		if len(c.CORSMethods) == 0 {
+			v.add("CORSMethods", "methods list must not be empty")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ConfigError{Problems: v.problems}
+	}
+	return nil
+}
+
+// addProblem adds a Config values parsing problem
+func (c *Config) addProblem(field string, key string, msg string) {
+	c.problems = append(c.problems, &ConfigProblem{
+		Field:   field,
+		Key:     key,
+		Message: msg,
+	})
+}
+
+// configValidator collects Config problems
+type configValidator struct {
+	problems []*ConfigProblem
+}
+
+// add adds a problem of the field
+func (v *configValidator) add(field string, msg string) {
+	v.problems = append(v.problems, &ConfigProblem{
+		Field:   field,
+		Key:     ConfigKeys()[field],
+		Message: msg,
+	})
+}
+
+// url validates optional URL value
+func (v *configValidator) url(field string, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "http(s) URL expected, got `"+value+"`")
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+)
+
+// corsMiddleware is a CORS middleware which settings may be replaced on the config reload
+type corsMiddleware struct {
+	handler atomic.Value
+}
+
+// newCORSMiddleware creates corsMiddleware instance configured from the Config
+func newCORSMiddleware(conf *Config) *corsMiddleware {
+	m := &corsMiddleware{}
+	m.handler.Store(gin.HandlerFunc(func(*gin.Context) {}))
+	if err := m.apply(conf); err != nil {
+		log.Error(logTag, "invalid CORS config: ", err)
+	}
+	return m
+}
+
+// Handle is a gin middleware handler
+func (m *corsMiddleware) Handle(ctx *gin.Context) {
+	m.handler.Load().(gin.HandlerFunc)(ctx)
+}
+
+// apply replaces the middleware handler according to the Config.
+// The current handler is kept if the CORS settings are invalid.
+func (m *corsMiddleware) apply(conf *Config) (err error) {
+	if !conf.CORSEnable {
+		m.handler.Store(gin.HandlerFunc(func(*gin.Context) {}))
+		return nil
+	}
+
+	cc := cors.Config{
+		AllowWildcard: true,
+
+		AllowAllOrigins:        conf.CORSAllowAll,
+		AllowCredentials:       conf.CORSAllowCred,
+		AllowWebSockets:        conf.CORSAllowWS,
+		AllowBrowserExtensions: conf.CORSAllowExt,
+
+		AllowHeaders: conf.CORSHeaders,
+		AllowMethods: conf.CORSMethods,
+	}
+
+	if !cc.AllowAllOrigins {
+		cc.AllowOrigins = conf.CORSOrigins
+	}
+
+	// cors.New panics on invalid settings
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	m.handler.Store(cors.New(cc))
+
+	return nil
+}
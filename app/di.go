@@ -5,12 +5,14 @@ import (
 	"github.com/bubulearn/bubucore"
 	"github.com/bubulearn/bubucore/di"
 	"github.com/bubulearn/bubucore/ginsrv"
+	"github.com/bubulearn/bubucore/httpclient"
 	"github.com/bubulearn/bubucore/i18n"
 	"github.com/bubulearn/bubucore/mongodb"
 	"github.com/bubulearn/bubucore/notifications"
 	"github.com/bubulearn/bubucore/staticservice"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/bubulearn/bubucore/tracing"
 	"github.com/bubulearn/bubucore/users"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	log "github.com/sirupsen/logrus"
@@ -27,12 +29,14 @@ const (
 	DIConfig = "bubu_config"
 
 	// DII18n contains initialized i18n.TextsSource instance
-	// In case of renaming, see ginsrv/context.go:42
-	DII18n = "bubu_i18n"
+	DII18n = i18n.DISourceName
 
 	// DIRouter contains gin router (gin.Engine) instance
 	DIRouter = "bubu_router"
 
+	// DIRoutes contains ginsrv.Routes metadata registry instance
+	DIRoutes = "bubu_routes"
+
 	// DINotifications contains notifications.Client instance
 	DINotifications = "bubu_notifications"
 
@@ -47,13 +51,21 @@ const (
 
 	// DIRedis contains redis.Client instance, or nil if no redis host provided in config
 	DIRedis = "bubu_redis"
+
+	// DITracing contains tracing.Exporter instance set as the spans exporter,
+	// or nil if no export target provided in config
+	DITracing = "bubu_tracing"
+
+	// DIJWTKeys contains tokens.KeySet or tokens.KeySource instance set as the JWT key provider,
+	// or nil if neither JWT keys nor JWKS URL provided in config
+	DIJWTKeys = "bubu_jwt_keys"
 )
 
 // GetDefaultDIBuilder returns default DI builder
 func GetDefaultDIBuilder() (*di.Builder, error) {
 	builder := &di.Builder{}
 
-	err := builder.Add(DIDefConfigViper(), DIDefConfig(), DIDefI18n(), DIDefRouter())
+	err := builder.Add(DIDefConfigViper(), DIDefConfig(), DIDefI18n(), DIDefRouter(), DIDefRoutes(), DIDefTracing(), DIDefJWTKeys())
 	if err != nil {
 		return nil, err
 	}
@@ -86,12 +98,13 @@ func BuildDefaultContainer() *di.Container {
 	return ctn
 }
 
-// DIDefConfigViper returns app config read from config file to the viper.Viper instance
+// DIDefConfigViper returns app config loaded to the viper.Viper instance,
+// see bubucore.LoadConfig for the config layers
 func DIDefConfigViper() di.Def {
 	return di.Def{
 		Name: DIConfigViper,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			return bubucore.ReadConfig()
+			return bubucore.LoadConfig(ConfigDefaults())
 		},
 	}
 }
@@ -101,11 +114,16 @@ func DIDefConfig() di.Def {
 	return di.Def{
 		Name: DIConfig,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			vpr := ctn.Get(DIConfigViper).(*viper.Viper)
+			vpr := DIGetConfigViper(ctn)
 
 			conf := &Config{}
 			conf.SetFromViper(vpr)
 
+			err := conf.Validate()
+			if err != nil {
+				return nil, err
+			}
+
 			return conf, nil
 		},
 	}
@@ -117,7 +135,7 @@ func DIDefI18n() di.Def {
 		Name: DII18n,
 		Build: func(ctn *di.Container) (interface{}, error) {
 			var err error
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			if conf.I18nFile != "" {
 				i18n.Source, err = i18n.NewSourceFromFile(conf.I18nFile)
 			}
@@ -131,33 +149,135 @@ func DIDefRouter() di.Def {
 	return di.Def{
 		Name: DIRouter,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			router := ginsrv.GetDefaultRouter()
 
 			// CORS
-			if conf.CORSEnable {
-				cc := cors.Config{
-					AllowWildcard: true,
+			corsMw := newCORSMiddleware(conf)
+			router.Use(corsMw.Handle)
+			conf.OnChange(func(old *Config, new *Config) {
+				if err := corsMw.apply(new); err != nil {
+					log.Error(logTag, "failed to reload CORS config: ", err)
+				}
+			})
 
-					AllowAllOrigins:        conf.CORSAllowAll,
-					AllowCredentials:       conf.CORSAllowCred,
-					AllowWebSockets:        conf.CORSAllowWS,
-					AllowBrowserExtensions: conf.CORSAllowExt,
+			return router, nil
+		},
+	}
+}
 
-					AllowHeaders: conf.CORSHeaders,
-					AllowMethods: conf.CORSMethods,
-				}
+// DIDefRoutes returns default ginsrv.Routes dependency definition
+func DIDefRoutes() di.Def {
+	return di.Def{
+		Name: DIRoutes,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return ginsrv.NewRoutes(), nil
+		},
+	}
+}
 
-				if !cc.AllowAllOrigins {
-					cc.AllowOrigins = conf.CORSOrigins
+// DIDefTracing returns default tracing.Exporter dependency definition.
+// The exporter is set as the global spans exporter, see tracing.SetExporter.
+// Returns nil if no Config.TracingExport defined in config.
+func DIDefTracing() di.Def {
+	return di.Def{
+		Name: DITracing,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			conf := DIGetConfig(ctn)
+			if conf.TracingExport == "" {
+				return nil, nil
+			}
+			exporter, err := tracing.NewExporter(conf.TracingExport, bubucore.Opt.ServiceName)
+			if err != nil {
+				return nil, err
+			}
+			tracing.SetExporter(exporter)
+			return exporter, nil
+		},
+		Close: func(obj interface{}) error {
+			if obj == nil {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return tracing.Shutdown(ctx)
+		},
+	}
+}
+
+// DIDefJWTKeys returns default JWT key provider dependency definition:
+// tokens.KeySource fetching the Config.JWKSURL or tokens.KeySet loaded from the Config.JWTKeys files.
+// The provider is set as the JWT key provider, see tokens.SetKeyProvider,
+// the key files are loaded again on every config reload to rotate the keys,
+// the KeySource is replaced and closed if the JWKS URL changes.
+// Returns nil if neither defined in config, JWT are verified with the Config.JWTPassword then.
+func DIDefJWTKeys() di.Def {
+	return di.Def{
+		Name: DIJWTKeys,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			conf := DIGetConfig(ctn)
+			provider, err := loadJWTKeys(conf, nil)
+			if err != nil {
+				return nil, err
+			}
+			conf.OnChange(func(old *Config, new *Config) {
+				reloaded, err := loadJWTKeys(new, provider)
+				if err != nil {
+					log.Error(logTag, "failed to reload JWT keys, keeping the previous ones: ", err)
+					return
+				}
+				_, _ = ctn.Swap(DIJWTKeys, reloaded)
+				if src, ok := provider.(*tokens.KeySource); ok && src != reloaded {
+					_ = src.Close()
 				}
+				provider = reloaded
+			})
+			return provider, nil
+		},
+		Close: func(obj interface{}) error {
+			tokens.SetKeyProvider(nil)
+			if src, ok := obj.(*tokens.KeySource); ok {
+				return src.Close()
+			}
+			return nil
+		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			if src, ok := obj.(*tokens.KeySource); ok && src.Keys() == nil {
+				return bubucore.ErrKeysUnavailable
+			}
+			return nil
+		},
+	}
+}
 
-				router.Use(cors.New(cc))
+// loadJWTKeys creates the Config's JWT key provider and sets it as the JWT key provider.
+// The running tokens.KeySource is kept if its URL is not changed to keep the cached keys.
+// Switches to the HMAC mode and returns nil if neither JWKS URL nor keys files defined.
+func loadJWTKeys(conf *Config, running interface{}) (interface{}, error) {
+	if conf.JWKSURL != "" {
+		src, ok := running.(*tokens.KeySource)
+		if !ok || src.URL() != conf.JWKSURL {
+			src = tokens.NewKeySource(conf.JWKSURL, tokens.KeySourceOptions{TTL: conf.JWKSTTL})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := src.Refresh(ctx); err != nil {
+				log.Warn(logTag, "failed to fetch JWKS, retrying on demand: ", err)
 			}
+		}
+		tokens.SetKeyProvider(src)
+		return src, nil
+	}
 
-			return router, nil
-		},
+	if len(conf.JWTKeys) == 0 {
+		tokens.SetKeyProvider(nil)
+		return nil, nil
+	}
+	keys, err := tokens.LoadKeySet(conf.JWTKeys...)
+	if err != nil {
+		return nil, err
 	}
+	tokens.SetKeyProvider(keys)
+	return keys, nil
 }
 
 // DIDefNotifications returns default notifications.Client dependency definition
@@ -165,8 +285,9 @@ func DIDefNotifications() di.Def {
 	return di.Def{
 		Name: DINotifications,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			client := notifications.NewClient(conf.NotificationsHost, conf.NotificationsToken)
+			applyHTTPPolicies(conf, client.HTTP())
 			if conf.NotificationsHost != "" {
 				err := client.Ping()
 				if err != nil {
@@ -178,6 +299,16 @@ func DIDefNotifications() di.Def {
 		Close: func(obj interface{}) error {
 			return obj.(*notifications.Client).Close()
 		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			client := obj.(*notifications.Client)
+			if client.Host() == "" {
+				return nil
+			}
+			if err := client.HTTP().CheckBreakers(); err != nil {
+				return err
+			}
+			return client.PingContext(ctx)
+		},
 	}
 }
 
@@ -186,12 +317,16 @@ func DIDefUsersService() di.Def {
 	return di.Def{
 		Name: DIUsersService,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			client := users.NewClient(conf.UsersServiceHost, conf.UsersServiceToken)
+			applyHTTPPolicies(conf, client.HTTP())
 
 			if conf.UsersServiceUseRedis {
 				redisClient := DIGetRedis(ctn)
 				client.SetRedis(redisClient, conf.UsersServiceTTL)
+				conf.OnChange(func(old *Config, new *Config) {
+					client.SetCacheTTL(new.UsersServiceTTL)
+				})
 			}
 
 			return client, nil
@@ -199,6 +334,9 @@ func DIDefUsersService() di.Def {
 		Close: func(obj interface{}) error {
 			return obj.(*users.Client).Close()
 		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			return obj.(*users.Client).HTTP().CheckBreakers()
+		},
 	}
 }
 
@@ -207,23 +345,40 @@ func DIDefStaticService() di.Def {
 	return di.Def{
 		Name: DIStaticService,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			client := staticservice.NewClient(conf.StaticServiceHost, conf.StaticServiceSign)
+			applyHTTPPolicies(conf, client.HTTP())
 			return client, nil
 		},
 		Close: func(obj interface{}) error {
 			return obj.(*staticservice.Client).Close()
 		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			return obj.(*staticservice.Client).HTTP().CheckBreakers()
+		},
 	}
 }
 
+// applyHTTPPolicies sets the Config's retry and circuit breaker policies to the services client
+// and keeps them up to date on the config reload
+func applyHTTPPolicies(conf *Config, client *httpclient.Client) {
+	client.SetRetryPolicy(conf.RetryPolicy())
+	client.SetBreakerPolicy(conf.BreakerPolicy())
+	conf.OnChange(func(old *Config, new *Config) {
+		client.SetRetryPolicy(new.RetryPolicy())
+		if new.HTTPBreakerThreshold != old.HTTPBreakerThreshold || new.HTTPBreakerTimeout != old.HTTPBreakerTimeout {
+			client.SetBreakerPolicy(new.BreakerPolicy())
+		}
+	})
+}
+
 // DIDefMongo returns default mongodb.MongoDB dependency definition.
 // Returns nil if no Config.MongoHost defined in config.
 func DIDefMongo() di.Def {
 	return di.Def{
 		Name: DIMongo,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			if conf.MongoHost == "" {
 				return nil, nil
 			}
@@ -242,6 +397,13 @@ func DIDefMongo() di.Def {
 			}
 			return nil
 		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			m, ok := obj.(*mongodb.MongoDB)
+			if ok && m != nil {
+				return m.Ping(ctx)
+			}
+			return nil
+		},
 	}
 }
 
@@ -251,7 +413,7 @@ func DIDefRedis() di.Def {
 	return di.Def{
 		Name: DIRedis,
 		Build: func(ctn *di.Container) (interface{}, error) {
-			conf := ctn.Get(DIConfig).(*Config)
+			conf := DIGetConfig(ctn)
 			if conf.RedisHost == "" {
 				return nil, nil
 			}
@@ -279,47 +441,59 @@ func DIDefRedis() di.Def {
 			}
 			return nil
 		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			client, ok := obj.(*redis.Client)
+			if ok && client != nil {
+				return client.Ping(ctx).Err()
+			}
+			return nil
+		},
 	}
 }
 
 // DIGetConfigViper returns config viper.Viper from the DI container
 func DIGetConfigViper(ctn *di.Container) *viper.Viper {
-	return ctn.Get(DIConfigViper).(*viper.Viper)
+	return di.MustGet[*viper.Viper](ctn, DIConfigViper)
 }
 
 // DIGetConfig returns Config from the DI container
 func DIGetConfig(ctn *di.Container) *Config {
-	return ctn.Get(DIConfig).(*Config)
+	return di.MustGet[*Config](ctn, DIConfig)
 }
 
 // DIGetI18n returns i18n.TextsSource from the DI container
 func DIGetI18n(ctn *di.Container) *i18n.TextsSource {
-	return ctn.Get(DII18n).(*i18n.TextsSource)
+	return di.MustGet[*i18n.TextsSource](ctn, DII18n)
 }
 
 // DIGetRouter returns gin.Engine router from the DI container
 func DIGetRouter(ctn *di.Container) *gin.Engine {
-	return ctn.Get(DIRouter).(*gin.Engine)
+	return di.MustGet[*gin.Engine](ctn, DIRouter)
+}
+
+// DIGetRoutes returns ginsrv.Routes from the DI container
+func DIGetRoutes(ctn *di.Container) *ginsrv.Routes {
+	return di.MustGet[*ginsrv.Routes](ctn, DIRoutes)
 }
 
 // DIGetNotifications returns notifications.Client from the DI container
 func DIGetNotifications(ctn *di.Container) *notifications.Client {
-	return ctn.Get(DINotifications).(*notifications.Client)
+	return di.MustGet[*notifications.Client](ctn, DINotifications)
 }
 
 // DIGetUsersService returns users.Client from the DI container
 func DIGetUsersService(ctn *di.Container) *users.Client {
-	return ctn.Get(DIUsersService).(*users.Client)
+	return di.MustGet[*users.Client](ctn, DIUsersService)
 }
 
 // DIGetStaticService returns staticservice.Client from the DI container
 func DIGetStaticService(ctn *di.Container) *staticservice.Client {
-	return ctn.Get(DIStaticService).(*staticservice.Client)
+	return di.MustGet[*staticservice.Client](ctn, DIStaticService)
 }
 
 // DIGetMongoDB returns mongodb.MongoDB from the DI container
 func DIGetMongoDB(ctn *di.Container) *mongodb.MongoDB {
-	m := ctn.Get(DIMongo).(*mongodb.MongoDB)
+	m := di.MustGet[*mongodb.MongoDB](ctn, DIMongo)
 	if m == nil {
 		log.Fatal(logTag, "attempt to access nil MongoDB instance")
 	}
@@ -328,7 +502,7 @@ func DIGetMongoDB(ctn *di.Container) *mongodb.MongoDB {
 
 // DIGetRedis returns redis.Client from the DI container
 func DIGetRedis(ctn *di.Container) *redis.Client {
-	r := ctn.Get(DIRedis).(*redis.Client)
+	r := di.MustGet[*redis.Client](ctn, DIRedis)
 	if r == nil {
 		log.Fatal(logTag, "attempt to access nil redis client instance")
 	}
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ConfigChangeFn is a Config change subscriber function
+type ConfigChangeFn func(old *Config, new *Config)
+
+// configSubsMu guards lazy configSubs creation
+var configSubsMu sync.Mutex
+
+// configSubs is a Config change subscribers list
+type configSubs struct {
+	mu  sync.RWMutex
+	fns []ConfigChangeFn
+}
+
+// OnChange subscribes fn to the Config reloads, see ReloadConfig.
+// Subscribers are kept by the reloaded Config instances.
+func (c *Config) OnChange(fn ConfigChangeFn) {
+	configSubsMu.Lock()
+	if c.subs == nil {
+		c.subs = &configSubs{}
+	}
+	subs := c.subs
+	configSubsMu.Unlock()
+
+	subs.mu.Lock()
+	subs.fns = append(subs.fns, fn)
+	subs.mu.Unlock()
+}
+
+// Diff returns names of the fields which values differ in the other Config
+func (c *Config) Diff(other *Config) []string {
+	diff := make([]string, 0)
+	v1 := reflect.ValueOf(c).Elem()
+	v2 := reflect.ValueOf(other).Elem()
+	t := v1.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("config") == "" {
+			continue
+		}
+		if !reflect.DeepEqual(v1.Field(i).Interface(), v2.Field(i).Interface()) {
+			diff = append(diff, t.Field(i).Name)
+		}
+	}
+	return diff
+}
+
+// IsReloadable checks if the Config field is applied on reload without restart
+func IsReloadable(field string) bool {
+	f, ok := reflect.TypeOf(Config{}).FieldByName(field)
+	return ok && f.Tag.Get("reload") == "true"
+}
+
+// notify calls change subscribers
+func (c *Config) notify(old *Config) {
+	if c.subs == nil {
+		return
+	}
+	c.subs.mu.RLock()
+	fns := append([]ConfigChangeFn{}, c.subs.fns...)
+	c.subs.mu.RUnlock()
+	for _, fn := range fns {
+		fn(old, c)
+	}
+}
+
+// keepRunning copies values of the non-reloadable fields from the running Config.
+// Returns names of the fields which changes require restart.
+func (c *Config) keepRunning(running *Config) []string {
+	restart := make([]string, 0)
+	v := reflect.ValueOf(c).Elem()
+	vr := reflect.ValueOf(running).Elem()
+	for _, field := range c.Diff(running) {
+		if IsReloadable(field) {
+			continue
+		}
+		restart = append(restart, field)
+		v.FieldByName(field).Set(vr.FieldByName(field))
+	}
+	return restart
+}
+
+// ReloadConfig loads the config again, validates it and atomically swaps
+// the Config and the config viper in the DI container, then notifies OnChange subscribers.
+// Fields which are not reloadable keep their running values, their names are returned.
+func ReloadConfig(ctn *di.Container) (restartRequired []string, err error) {
+	vpr, err := bubucore.LoadConfig(ConfigDefaults())
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &Config{}
+	conf.setFromViper(vpr)
+
+	err = conf.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	old := DIGetConfig(ctn)
+
+	restartRequired = conf.keepRunning(old)
+	conf.subs = old.subs
+
+	_, err = ctn.Swap(DIConfigViper, vpr)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ctn.Swap(DIConfig, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	conf.ApplyToGlobals()
+	conf.notify(old)
+
+	return restartRequired, nil
+}
+
+// ReloadConfig reloads the App's config, see ReloadConfig
+func (a *App) ReloadConfig() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	restart, err := ReloadConfig(a.C())
+	if err != nil {
+		log.Error(logTag, "failed to reload config: ", err)
+		return err
+	}
+
+	if len(restart) > 0 {
+		log.Warn(logTag, "config reloaded, changes of ", strings.Join(restart, ", "), " require restart")
+	} else {
+		log.Info(logTag, "config reloaded")
+	}
+
+	return nil
+}
+
+// watchConfig reloads config on SIGHUP and on the config file change if Config.ConfigWatch is enabled.
+// Returns function to stop watching.
+func (a *App) watchConfig() (stop func()) {
+	hup := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				_ = a.ReloadConfig()
+			}
+		}
+	}()
+
+	var watcher *fsnotify.Watcher
+	conf := DIGetConfig(a.C())
+	if conf.ConfigWatch {
+		if _, err := os.Stat(bubucore.Opt.ConfigFilePath); err == nil {
+			watcher, err = watchConfigFile(bubucore.Opt.ConfigFilePath, func() {
+				select {
+				case <-done:
+				default:
+					_ = a.ReloadConfig()
+				}
+			})
+			if err != nil {
+				log.Error(logTag, "failed to watch config file: ", err)
+			}
+		}
+	}
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+		if watcher != nil {
+			_ = watcher.Close()
+		}
+	}
+}
+
+// watchConfigFile calls fn on the config file writes and symlink changes, e. g. on the k8s ConfigMap update.
+// The file directory is watched to catch the atomic saves. Close the returned watcher to stop watching.
+func watchConfigFile(path string, fn func()) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Clean(path)
+	realFile, _ := filepath.EvalSymlinks(file)
+
+	err = watcher.Add(filepath.Dir(file))
+	if err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				currFile, _ := filepath.EvalSymlinks(file)
+				written := filepath.Clean(event.Name) == file && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+				if written || (currFile != "" && currFile != realFile) {
+					realFile = currFile
+					fn()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warn(logTag, "config file watcher error: ", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
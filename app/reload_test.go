@@ -0,0 +1,161 @@
+package app
+
+import (
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/bubulearn/bubucore/tokens"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+		log.SetLevel(log.InfoLevel)
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	builder := &di.Builder{}
+	err := builder.Add(DIDefConfigViper(), DIDefConfig())
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := builder.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ctn.Close()
+
+	conf := DIGetConfig(ctn)
+
+	var notified [2]*Config
+	conf.OnChange(func(old *Config, new *Config) {
+		notified = [2]*Config{old, new}
+	})
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("BUBU_USERS_TTL", "60")
+	t.Setenv("BUBU_SERVICE_PORT", "8080")
+
+	restart, err := ReloadConfig(ctn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"Port"}, restart)
+
+	reloaded := DIGetConfig(ctn)
+	assert.NotSame(t, conf, reloaded)
+	assert.Equal(t, "80", reloaded.Port)
+	assert.Equal(t, 60, reloaded.UsersServiceTTL)
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.Same(t, conf, notified[0])
+	assert.Same(t, reloaded, notified[1])
+
+	t.Setenv("BUBU_USERS_TTL", "invalid")
+
+	_, err = ReloadConfig(ctn)
+	assert.Error(t, err)
+	assert.Same(t, reloaded, DIGetConfig(ctn))
+}
+
+func TestReloadConfig_JWKSURL(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	closed := make(chan struct{}, 10)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[]}`))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	t.Setenv("BUBU_JWKS_URL", srv.URL+"/old")
+
+	builder := &di.Builder{}
+	err := builder.Add(DIDefConfigViper(), DIDefConfig(), DIDefJWTKeys())
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := builder.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ctn.Close()
+
+	old := ctn.Get(DIJWTKeys).(*tokens.KeySource)
+	assert.Equal(t, srv.URL+"/old", old.URL())
+
+	t.Setenv("BUBU_JWKS_URL", srv.URL+"/new")
+	_, err = ReloadConfig(ctn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, srv.URL+"/new", ctn.Get(DIJWTKeys).(*tokens.KeySource).URL())
+
+	// the replaced source connection is closed
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("replaced JWKS source is not closed")
+	}
+}
+
+func TestConfig_Diff(t *testing.T) {
+	c1 := &Config{Port: "80", CORSOrigins: []string{"a"}}
+	c2 := &Config{Port: "80", CORSOrigins: []string{"b"}, RedisDb: 1}
+
+	assert.Equal(t, []string{"CORSOrigins", "RedisDb"}, c1.Diff(c2))
+	assert.Empty(t, c1.Diff(c1))
+
+	assert.True(t, IsReloadable("CORSOrigins"))
+	assert.False(t, IsReloadable("RedisDb"))
+	assert.False(t, IsReloadable("Unknown"))
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if !assert.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=info\n"), 0600)) {
+		return
+	}
+
+	changed := make(chan struct{}, 10)
+	watcher, err := watchConfigFile(path, func() { changed <- struct{}{} })
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=debug\n"), 0600))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("config file change is not detected")
+	}
+
+	// no changes are reported after stop
+	assert.NoError(t, watcher.Close())
+	for len(changed) > 0 {
+		<-changed
+	}
+	assert.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=warn\n"), 0600))
+	select {
+	case <-changed:
+		t.Fatal("config file change is reported after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
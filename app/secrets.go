@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secret reference schemes
+const (
+	SecretSchemeFile = "file"
+	SecretSchemeEnv  = "env"
+)
+
+// secretRedacted replaces secret values in the Config dumps
+const secretRedacted = "[REDACTED]"
+
+// SecretProvider resolves secret references of its scheme.
+// The ref is a reference without the scheme prefix,
+// e. g. `///run/secrets/jwt` for the `file:///run/secrets/jwt` value.
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretProviderFn is a function adapter for SecretProvider
+type SecretProviderFn func(ref string) (string, error)
+
+// Resolve calls the fn
+func (fn SecretProviderFn) Resolve(ref string) (string, error) {
+	return fn(ref)
+}
+
+// FileSecretProvider reads secrets from files, e. g. `file:///run/secrets/jwt`.
+// Trailing line breaks are trimmed.
+type FileSecretProvider struct{}
+
+// Resolve reads the secret file
+func (p *FileSecretProvider) Resolve(ref string) (string, error) {
+	path := strings.TrimPrefix(ref, "//")
+	if path == "" {
+		return "", errors.New("secret file path is empty")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider reads secrets from environment variables, e. g. `env:JWT_SECRET`
+type EnvSecretProvider struct{}
+
+// Resolve reads the secret environment variable
+func (p *EnvSecretProvider) Resolve(ref string) (string, error) {
+	val, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errors.New("environment variable `" + ref + "` is not defined")
+	}
+	return val, nil
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		SecretSchemeFile: &FileSecretProvider{},
+		SecretSchemeEnv:  &EnvSecretProvider{},
+	}
+)
+
+// RegisterSecretProvider registers provider to resolve the `scheme:` prefixed secret references.
+// Replaces the provider registered for the scheme before, including the default ones.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[scheme] = provider
+}
+
+// ResolveSecret resolves the secret reference through the provider registered for its scheme.
+// Values without a registered scheme prefix are returned as is.
+func ResolveSecret(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	secretProvidersMu.RLock()
+	provider, ok := secretProviders[scheme]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	return provider.Resolve(ref)
+}
+
+// IsSecret checks if the Config field contains a secret, see the `secret` tag
+func IsSecret(field string) bool {
+	f, ok := reflect.TypeOf(Config{}).FieldByName(field)
+	return ok && f.Tag.Get("secret") == "true"
+}
+
+// Redacted returns the Config values by config keys with the secret values replaced
+func (c *Config) Redacted() map[string]interface{} {
+	res := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("config")
+		if key == "" {
+			continue
+		}
+		val := v.Field(i).Interface()
+		if t.Field(i).Tag.Get("secret") == "true" && v.Field(i).Len() > 0 {
+			val = secretRedacted
+		}
+		res[key] = val
+	}
+	return res
+}
+
+// String returns the Config with redacted secrets as a string
+func (c *Config) String() string {
+	values := c.Redacted()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", key, values[key])
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// MarshalJSON encodes the Config with redacted secrets
+func (c *Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Redacted())
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt")
+	err := os.WriteFile(path, []byte("file-secret\n"), 0600)
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Setenv("BUBU_TEST_SECRET", "env-secret")
+
+	RegisterSecretProvider("test", SecretProviderFn(func(ref string) (string, error) {
+		if ref == "fail" {
+			return "", errors.New("failed")
+		}
+		return "custom-" + ref, nil
+	}))
+
+	cases := map[string]string{
+		"file://" + path:       "file-secret",
+		"env:BUBU_TEST_SECRET": "env-secret",
+		"test:secret":          "custom-secret",
+		"plain":                "plain",
+		"unknown:value":        "unknown:value",
+		"":                     "",
+	}
+	for value, expected := range cases {
+		res, err := ResolveSecret(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, res, value)
+	}
+
+	for _, value := range []string{"file:///not/exists", "env:BUBU_TEST_UNDEFINED", "test:fail"} {
+		_, err = ResolveSecret(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestConfig_Secrets(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+	}()
+	bubucore.Opt.ConfigFilePath = "../.test.env"
+
+	t.Setenv("BUBU_JWT_PASSWORD", "env:BUBU_TEST_JWT")
+	t.Setenv("BUBU_TEST_JWT", "jwt-secret")
+	t.Setenv("REDIS_PASSWORD", "env:BUBU_TEST_UNDEFINED")
+
+	v, err := bubucore.LoadConfig(ConfigDefaults())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf := &Config{}
+	conf.setFromViper(v)
+
+	assert.Equal(t, []byte("jwt-secret"), conf.JWTPassword)
+
+	err = conf.Validate()
+	if assert.IsType(t, &ConfigError{}, err) {
+		problems := err.(*ConfigError).Problems
+		assert.Len(t, problems, 1)
+		assert.Equal(t, "RedisPassword", problems[0].Field)
+	}
+
+	dump := conf.String()
+	assert.True(t, strings.Contains(dump, "bubu_jwt_password="+secretRedacted), dump)
+	assert.True(t, strings.Contains(dump, "bubu_notifications_token="+secretRedacted), dump)
+	assert.True(t, strings.Contains(dump, "mongo_password= "), dump)
+	assert.False(t, strings.Contains(dump, "jwt-secret"), dump)
+	assert.False(t, strings.Contains(dump, "notifications-token"), dump)
+
+	data, err := json.Marshal(conf)
+	if assert.NoError(t, err) {
+		assert.False(t, strings.Contains(string(data), "jwt-secret"))
+		assert.True(t, strings.Contains(string(data), `"bubu_jwt_password":"`+secretRedacted+`"`))
+	}
+
+	assert.True(t, IsSecret("JWTPassword"))
+	assert.False(t, IsSecret("Port"))
+}
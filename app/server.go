@@ -0,0 +1,171 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixAddrPrefix is a listen address prefix for unix sockets
+const unixAddrPrefix = "unix:"
+
+// newServer creates http.Server configured with the Config
+func newServer(conf *Config, handler http.Handler) (*http.Server, error) {
+	if conf.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: conf.IdleTimeout})
+	}
+
+	srv := &http.Server{
+		Addr:              listenAddrs(conf)[0],
+		Handler:           handler,
+		ReadTimeout:       conf.ReadTimeout,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
+
+	if conf.TLSCertFile != "" {
+		certs, err := newCertReloader(conf.TLSCertFile, conf.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+
+	return srv, nil
+}
+
+// listenAddrs returns addresses to listen, `:port` if Config.ListenAddrs is empty
+func listenAddrs(conf *Config) []string {
+	if len(conf.ListenAddrs) > 0 {
+		return conf.ListenAddrs
+	}
+	return []string{":" + conf.Port}
+}
+
+// listen announces on the address, either TCP `host:port` or `unix:/path/to.sock`.
+// Stale unix socket file is removed before listening.
+func listen(addr string) (net.Listener, error) {
+	path, ok := unixSocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+
+	return net.Listen("unix", path)
+}
+
+// unixSocketPath returns unix socket path of the `unix:/path` or `unix:///path` address
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return "", false
+	}
+	path := strings.TrimPrefix(addr, unixAddrPrefix)
+	if strings.HasPrefix(path, "//") {
+		path = strings.TrimPrefix(path, "//")
+	}
+	return path, true
+}
+
+// certReloaderCheckInterval is a minimal interval between the certificate files checks
+const certReloaderCheckInterval = time.Second
+
+// certReloader serves TLS certificate, reloading it when the files are modified
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader creates certReloader instance with the certificate loaded
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns actual certificate, see tls.Config.GetCertificate.
+// Keeps the loaded certificate if the modified files are invalid.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	cert := r.cert
+	check := time.Since(r.checkedAt) >= certReloaderCheckInterval
+	r.mu.RUnlock()
+
+	if check && r.modified() {
+		if err := r.load(); err != nil {
+			log.Error(logTag, "failed to reload TLS certificate: ", err)
+		}
+		r.mu.RLock()
+		cert = r.cert
+		r.mu.RUnlock()
+	}
+
+	return cert, nil
+}
+
+// modified checks if the certificate files are modified after the last load
+func (r *certReloader) modified() bool {
+	modTime := r.filesModTime()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkedAt = time.Now()
+
+	return modTime.After(r.modTime)
+}
+
+// load loads the certificate files
+func (r *certReloader) load() error {
+	modTime := r.filesModTime()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.New(logTag + "failed to load TLS certificate: " + err.Error())
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// filesModTime returns the latest modification time of the certificate files
+func (r *certReloader) filesModTime() time.Time {
+	var res time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(res) {
+			res = info.ModTime()
+		}
+	}
+	return res
+}
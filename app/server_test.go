@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApp_ServeAddrs(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+
+	conf := &Config{
+		ListenAddrs:       []string{"127.0.0.1:0", "unix:" + sock},
+		H2C:               true,
+		ReadHeaderTimeout: time.Second,
+		MaxHeaderBytes:    4096,
+		ShutdownTimeout:   time.Second,
+	}
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: DIConfig,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return conf, nil
+			},
+		},
+		di.Def{
+			Name: DIRouter,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				router := gin.New()
+				router.GET("/proto", func(ctx *gin.Context) {
+					ctx.String(http.StatusOK, ctx.Request.Proto)
+				})
+				return router, nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := NewApp(ctn)
+	srv := a.Server()
+	assert.Equal(t, time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 4096, srv.MaxHeaderBytes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.Serve(ctx)
+	}()
+
+	var addrs []net.Addr
+	for i := 0; i < 100 && len(addrs) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		addrs = a.Addrs()
+	}
+	if !assert.Len(t, addrs, 2) {
+		cancel()
+		return
+	}
+
+	// HTTP/1.1 over TCP
+	resp, err := http.Get("http://" + addrs[0].String() + "/proto")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, resp.ProtoMajor)
+		_ = resp.Body.Close()
+	}
+
+	// HTTP/2 over cleartext TCP
+	h2cClient := &http.Client{Timeout: 3 * time.Second, Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err = h2cClient.Get("http://" + addrs[0].String() + "/proto")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, resp.ProtoMajor)
+		_ = resp.Body.Close()
+	}
+
+	// unix socket
+	unixClient := &http.Client{Timeout: 3 * time.Second, Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err = unixClient.Get("http://unix/proto")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeTestCert(t, certFile, keyFile, "first")
+
+	r, err := newCertReloader(certFile, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cert, err := r.GetCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", certCommonName(t, cert))
+	}
+
+	writeTestCert(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	r.checkedAt = time.Time{}
+
+	cert, err = r.GetCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "second", certCommonName(t, cert))
+	}
+
+	// invalid files keep the loaded certificate
+	_ = os.WriteFile(keyFile, []byte("invalid"), 0600)
+	later = later.Add(time.Minute)
+	_ = os.Chtimes(keyFile, later, later)
+	r.checkedAt = time.Time{}
+
+	cert, err = r.GetCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "second", certCommonName(t, cert))
+	}
+
+	_, err = newCertReloader(certFile, keyFile)
+	assert.Error(t, err)
+}
+
+func TestUnixSocketPath(t *testing.T) {
+	path, ok := unixSocketPath("unix:///run/app.sock")
+	assert.True(t, ok)
+	assert.Equal(t, "/run/app.sock", path)
+
+	path, ok = unixSocketPath("unix:app.sock")
+	assert.True(t, ok)
+	assert.Equal(t, "app.sock", path)
+
+	_, ok = unixSocketPath(":80")
+	assert.False(t, ok)
+}
+
+func writeTestCert(t *testing.T, certFile string, keyFile string, cn string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certCommonName(t *testing.T, cert *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
@@ -2,10 +2,9 @@
 package b9s
 
 import (
+	"context"
 	"github.com/bubulearn/bubucore"
-	jsoniter "github.com/json-iterator/go"
-	"io/ioutil"
-	"net"
+	"github.com/bubulearn/bubucore/httpclient"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,13 +14,35 @@ const (
 	host = "https://api.backendless.com"
 )
 
+// logTag is a b9s errors prefix
+const logTag = "[bubucore][b9s]"
+
 // NewClient creates a Client instance
 func NewClient(opt *ClientOpt) *Client {
 	return &Client{
-		opt: opt,
+		opt:    opt,
+		client: httpclient.New(httpOptions()),
 	}
 }
 
+// httpOptions returns the underlying httpclient.Client options
+func httpOptions() httpclient.Options {
+	return httpclient.Options{
+		Name:        "b9s",
+		LogTag:      logTag,
+		Timeout:     30 * time.Second,
+		DecodeError: decodeError,
+	}
+}
+
+// decodeError maps any non-2xx B9s response to the http.StatusBadGateway error
+func decodeError(status int, body []byte) error {
+	return bubucore.NewError(
+		http.StatusBadGateway,
+		"b9s: got code "+strconv.Itoa(status)+"; resp: "+string(body),
+	)
+}
+
 // ClientOpt is a B9s Client options
 type ClientOpt struct {
 	// ProjectID is a B9s project UUID
@@ -33,8 +54,13 @@ type ClientOpt struct {
 
 // Client is a B9s client
 type Client struct {
-	opt     *ClientOpt
-	_client *http.Client
+	opt    *ClientOpt
+	client *httpclient.Client
+}
+
+// HTTP returns the underlying httpclient.Client
+func (c *Client) HTTP() *httpclient.Client {
+	return c.client
 }
 
 // NewRequest creates new b9s GET request
@@ -46,53 +72,21 @@ func (c *Client) NewRequest(table string) *Request {
 	}
 }
 
-// Do executes the Request and puts parsed response JSON to the target
+// Do executes the Request and puts parsed response JSON to the target, see DoContext
 func (c *Client) Do(req *Request, target interface{}) error {
-	r, err := http.NewRequest(http.MethodGet, req.String(), nil)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.client().Do(r)
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		bb, _ := ioutil.ReadAll(resp.Body)
-		return bubucore.NewError(
-			http.StatusBadGateway,
-			"b9s: got code "+strconv.FormatInt(int64(resp.StatusCode), 10)+"; resp: "+string(bb),
-		)
-	}
+	return c.DoContext(context.Background(), req, target)
+}
 
-	err = jsoniter.NewDecoder(resp.Body).Decode(target)
+// DoContext executes the Request and puts parsed response JSON to the target
+func (c *Client) DoContext(ctx context.Context, req *Request, target interface{}) error {
+	r, err := c.client.NewRequest(ctx, http.MethodGet, req.String(), nil)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return c.client.DoRequest(r, target)
 }
 
-// client returns http.Client
-func (c *Client) client() *http.Client {
-	if c._client == nil {
-		tr := &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   60 * time.Second,
-				KeepAlive: 60 * time.Second,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   15 * time.Second,
-			ExpectContinueTimeout: 5 * time.Second,
-		}
-		c._client = &http.Client{
-			Transport: tr,
-			Timeout:   30 * time.Second,
-		}
-	}
-	return c._client
+// Close finalizes the Client
+func (c *Client) Close() error {
+	return c.client.Close()
 }
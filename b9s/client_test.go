@@ -0,0 +1,53 @@
+package b9s
+
+import (
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/httpclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// hostTransport sends all the requests to the test server
+type hostTransport struct {
+	u *url.URL
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.u.Scheme
+	req.URL.Host = t.u.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_DoError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/project/key/data/lessons", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":3064,"message":"Not existing user token"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	opt := httpOptions()
+	opt.Transport = &hostTransport{u: u}
+
+	c := &Client{
+		opt:    &ClientOpt{ProjectID: "project", APIKey: "key"},
+		client: httpclient.New(opt),
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	err := c.Do(c.NewRequest("lessons"), &[]map[string]interface{}{})
+	e := &bubucore.Error{}
+	if !assert.True(t, errors.As(err, &e)) {
+		return
+	}
+	assert.Equal(t, http.StatusBadGateway, e.Code)
+	assert.Contains(t, e.Message, "got code 401")
+	assert.Contains(t, e.Message, "Not existing user token")
+}
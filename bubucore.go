@@ -2,8 +2,10 @@ package bubucore
 
 import (
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"os"
+	"sync"
 )
 
 // Opt shares package options
@@ -24,8 +26,14 @@ var Opt = &Options{
 type Options struct {
 	// ConfigFilePath is a path to an app config file
 	ConfigFilePath string
-	// ConfigFileType is an app config file type, e. g. "env"
+	// ConfigFileType is an app config file type, e. g. "env", "yaml" or "json".
+	// If empty, the type is detected by the file extension.
 	ConfigFileType string
+	// ConfigEnvPrefix is a prefix of environment variables to read config values from,
+	// e. g. with "APP" prefix bubu_service_port key is read from APP_BUBU_SERVICE_PORT
+	ConfigEnvPrefix string
+	// ConfigFlags is a parsed command-line flags set to read config values from
+	ConfigFlags *pflag.FlagSet
 
 	// LogsPath is a path to the log files directory
 	LogsPath string
@@ -48,13 +56,34 @@ type Options struct {
 	// ServiceRepo is an URL to service git repository
 	ServiceRepo string
 
+	// DisableServiceRoutes disables health & info endpoints mounting under the APIBasePath
+	DisableServiceRoutes bool
+
 	// Hostname of current node if is required to override os.Hostname() value
 	Hostname string
 
-	// JWTPassword is JWT password key
+	// JWTPassword is JWT password key.
+	// Use SetJWTPassword and GetJWTPassword to access it concurrently, e. g. on the config reload.
 	JWTPassword []byte
 }
 
+// jwtPasswordMu guards the Options.JWTPassword changes at runtime
+var jwtPasswordMu sync.RWMutex
+
+// SetJWTPassword sets the JWT password key, safe for concurrent use with GetJWTPassword
+func (o *Options) SetJWTPassword(password []byte) {
+	jwtPasswordMu.Lock()
+	o.JWTPassword = password
+	jwtPasswordMu.Unlock()
+}
+
+// GetJWTPassword returns the JWT password key, safe for concurrent use with SetJWTPassword
+func (o *Options) GetJWTPassword() []byte {
+	jwtPasswordMu.RLock()
+	defer jwtPasswordMu.RUnlock()
+	return o.JWTPassword
+}
+
 // GetHostname returns hostname from options or OS
 func (o *Options) GetHostname() string {
 	if o.Hostname == "" {
@@ -83,7 +112,8 @@ func GetServiceInfo() *ServiceInfo {
 	}
 }
 
-// ReadConfig loads configuration from the config file to the viper instance
+// ReadConfig loads configuration from the config file to the viper instance.
+// Fails if the config file does not exist, see LoadConfig for the layered configuration.
 func ReadConfig() (*viper.Viper, error) {
 	conf := viper.New()
 
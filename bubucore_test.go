@@ -25,6 +25,24 @@ func TestOptions_GetHostname(t *testing.T) {
 	assert.Equal(t, osHost, opt.GetHostname())
 }
 
+func TestOptions_JWTPassword(t *testing.T) {
+	opt := &bubucore.Options{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			opt.SetJWTPassword([]byte("rotated"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = opt.GetJWTPassword()
+	}
+	<-done
+
+	assert.Equal(t, []byte("rotated"), opt.GetJWTPassword())
+}
+
 func TestReadConfig(t *testing.T) {
 	bubucore.Opt.ConfigFilePath = ".test.env"
 	bubucore.Opt.ConfigFileType = "env"
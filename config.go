@@ -0,0 +1,104 @@
+package bubucore
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ConfigLayer is a configuration values source
+type ConfigLayer string
+
+// Configuration layers, in order of priority increasing
+const (
+	ConfigLayerDefault = ConfigLayer("default")
+	ConfigLayerFile    = ConfigLayer("file")
+	ConfigLayerEnv     = ConfigLayer("env")
+	ConfigLayerFlag    = ConfigLayer("flag")
+)
+
+// LoadConfig loads layered configuration to the viper instance.
+// Each next layer overrides the previous one:
+//   - defaults given;
+//   - config file at Options.ConfigFilePath, skipped if file does not exist;
+//   - environment variables, prefixed with Options.ConfigEnvPrefix if defined;
+//   - command-line flags from Options.ConfigFlags, e. g. --bubu-service-port for the bubu_service_port key.
+func LoadConfig(defaults map[string]interface{}) (*viper.Viper, error) {
+	conf := viper.New()
+
+	for key, val := range defaults {
+		conf.SetDefault(key, val)
+	}
+
+	if Opt.ConfigFilePath != "" {
+		conf.SetConfigFile(Opt.ConfigFilePath)
+		if Opt.ConfigFileType != "" {
+			conf.SetConfigType(Opt.ConfigFileType)
+		}
+		err := conf.ReadInConfig()
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			log.Info("config file ", Opt.ConfigFilePath, " does not exist, skipping")
+		}
+	}
+
+	if Opt.ConfigEnvPrefix != "" {
+		conf.SetEnvPrefix(Opt.ConfigEnvPrefix)
+	}
+	conf.AutomaticEnv()
+
+	if Opt.ConfigFlags != nil {
+		var err error
+		Opt.ConfigFlags.VisitAll(func(f *pflag.Flag) {
+			if err == nil {
+				err = conf.BindPFlag(ConfigFlagKey(f.Name), f)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conf, nil
+}
+
+// ConfigLayerOf returns the layer the key value is loaded from by LoadConfig
+func ConfigLayerOf(conf *viper.Viper, key string) ConfigLayer {
+	if Opt.ConfigFlags != nil {
+		f := Opt.ConfigFlags.Lookup(ConfigKeyFlag(key))
+		if f != nil && f.Changed {
+			return ConfigLayerFlag
+		}
+	}
+	if os.Getenv(ConfigKeyEnv(key)) != "" {
+		return ConfigLayerEnv
+	}
+	if conf.InConfig(key) {
+		return ConfigLayerFile
+	}
+	return ConfigLayerDefault
+}
+
+// ConfigKeyEnv returns environment variable name for the config key
+func ConfigKeyEnv(key string) string {
+	if Opt.ConfigEnvPrefix != "" {
+		key = Opt.ConfigEnvPrefix + "_" + key
+	}
+	return strings.ToUpper(key)
+}
+
+// ConfigKeyFlag returns command-line flag name for the config key
+func ConfigKeyFlag(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// ConfigFlagKey returns config key for the command-line flag name
+func ConfigFlagKey(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
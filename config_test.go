@@ -0,0 +1,47 @@
+package bubucore_test
+
+import (
+	"github.com/bubulearn/bubucore"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	initialPath := bubucore.Opt.ConfigFilePath
+	defer func() {
+		bubucore.Opt.ConfigFilePath = initialPath
+	}()
+
+	bubucore.Opt.ConfigFilePath = ".test.env"
+	bubucore.Opt.ConfigFileType = "env"
+
+	t.Setenv("OTHER_TEST_ENV_VAR", "env")
+
+	conf, err := bubucore.LoadConfig(map[string]interface{}{
+		"default_var":  "dft",
+		"test_env_var": "dft",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "dft", conf.GetString("default_var"))
+	assert.Equal(t, bubucore.ConfigLayerDefault, bubucore.ConfigLayerOf(conf, "default_var"))
+
+	assert.Equal(t, "test1", conf.GetString("test_env_var"))
+	assert.Equal(t, bubucore.ConfigLayerFile, bubucore.ConfigLayerOf(conf, "test_env_var"))
+
+	assert.Equal(t, "env", conf.GetString("other_test_env_var"))
+	assert.Equal(t, bubucore.ConfigLayerEnv, bubucore.ConfigLayerOf(conf, "other_test_env_var"))
+
+	bubucore.Opt.ConfigFilePath = "__unknown_file__.env"
+	conf, err = bubucore.LoadConfig(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "env", conf.GetString("other_test_env_var"))
+	}
+}
+
+func TestConfigKeyFlag(t *testing.T) {
+	assert.Equal(t, "bubu-service-port", bubucore.ConfigKeyFlag("BUBU_SERVICE_PORT"))
+	assert.Equal(t, "bubu_service_port", bubucore.ConfigFlagKey("bubu-service-port"))
+}
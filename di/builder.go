@@ -14,7 +14,7 @@ type Builder struct {
 func (b *Builder) Add(defs ...Def) error {
 	b.initContainer()
 	for _, def := range defs {
-		if _, exists := b.ctn.defs[def.Name]; exists {
+		if b.ctn.Has(def.Name) {
 			return errors.New("[bubucore.di] definition with name `" + def.Name + "` already exists")
 		}
 		if def.Validate != nil {
@@ -22,22 +22,107 @@ func (b *Builder) Add(defs ...Def) error {
 				return err
 			}
 		}
+		b.ctn.mu.Lock()
 		b.ctn.defs[def.Name] = def
+		b.ctn.mu.Unlock()
 		b.ord = append(b.ord, def.Name)
 	}
 	return nil
 }
 
-// Build prepares Container and builds non-lazy definitions
+// Replace replaces the registered definition with the same name, keeping its build order.
+// Useful to override a default dependency, e. g. with a stub in tests.
+func (b *Builder) Replace(def Def) error {
+	b.initContainer()
+	if !b.ctn.Has(def.Name) {
+		return errors.New("[bubucore.di] definition with name `" + def.Name + "` does not exist")
+	}
+	if def.Validate != nil {
+		if err := def.Validate(b.ctn); err != nil {
+			return err
+		}
+	}
+	b.ctn.mu.Lock()
+	b.ctn.defs[def.Name] = def
+	b.ctn.mu.Unlock()
+	return nil
+}
+
+// Remove removes the registered definition
+func (b *Builder) Remove(name string) error {
+	b.initContainer()
+	if !b.ctn.Has(name) {
+		return errors.New("[bubucore.di] definition with name `" + name + "` does not exist")
+	}
+	b.ctn.mu.Lock()
+	delete(b.ctn.defs, name)
+	b.ctn.mu.Unlock()
+	for i, n := range b.ord {
+		if n == name {
+			b.ord = append(b.ord[:i], b.ord[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Decorate wraps the registered definition's Build result with the fn.
+// Decorators are applied in order of addition.
+// Note the definition's Close receives the decorated object.
+func (b *Builder) Decorate(name string, fn DecorateFn) error {
+	b.initContainer()
+	b.ctn.mu.Lock()
+	defer b.ctn.mu.Unlock()
+
+	def, ok := b.ctn.defs[name]
+	if !ok {
+		return errors.New("[bubucore.di] definition with name `" + name + "` does not exist")
+	}
+
+	build := def.Build
+	if build == nil {
+		return errors.New("[bubucore.di] definition `" + name + "`: Build function is not defined")
+	}
+
+	def.Build = func(ctn *Container) (interface{}, error) {
+		obj, err := build(ctn)
+		if err != nil {
+			return nil, err
+		}
+		return fn(obj)
+	}
+	b.ctn.defs[name] = def
+
+	return nil
+}
+
+// Build prepares Container and builds non-lazy singleton definitions.
+// Definitions are built in order of addition, but dependencies requested
+// with Container.Get from the Build function are built first.
+// Returns CycleError if definitions depend on each other.
 func (b *Builder) Build() (*Container, error) {
 	b.initContainer()
 	for _, name := range b.ord {
+		b.ctn.mu.RLock()
 		def := b.ctn.defs[name]
-		if !def.Lazy {
-			if err := def.build(b.ctn); err != nil {
-				return nil, err
-			}
-			b.ctn.defs[name] = def
+		b.ctn.mu.RUnlock()
+		if def.Lazy || def.Scope != ScopeSingleton {
+			continue
+		}
+		if _, err := b.ctn.SafeGet(name); err != nil {
+			return nil, err
+		}
+	}
+	return b.ctn, nil
+}
+
+// BuildOnly prepares Container and builds only the named definitions and the dependencies they request.
+// Other definitions are built lazily on the first access.
+func (b *Builder) BuildOnly(names ...string) (*Container, error) {
+	b.initContainer()
+	for _, name := range names {
+		if _, err := b.ctn.SafeGet(name); err != nil {
+			return nil, err
 		}
 	}
 	return b.ctn, nil
@@ -46,8 +131,6 @@ func (b *Builder) Build() (*Container, error) {
 // initContainer creates container instance
 func (b *Builder) initContainer() {
 	if b.ctn == nil {
-		b.ctn = &Container{
-			defs: make(DefsMap),
-		}
+		b.ctn = newContainer()
 	}
 }
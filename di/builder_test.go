@@ -132,3 +132,175 @@ func TestBuilder_Build(t *testing.T) {
 	_, err = b.Build()
 	assert.Error(t, err)
 }
+
+func TestBuilder_Replace(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "client",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "real", nil
+			},
+		},
+		di.Def{
+			Name: "consumer",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "uses " + ctn.Get("client").(string), nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = b.Replace(di.Def{
+		Name: "client",
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return "stub", nil
+		},
+	})
+	assert.NoError(t, err)
+
+	err = b.Replace(di.Def{Name: "unknown"})
+	assert.Error(t, err)
+
+	err = b.Replace(di.Def{
+		Name: "client",
+		Validate: func(ctn *di.Container) error {
+			return errors.New("expected error")
+		},
+	})
+	assert.Error(t, err)
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "uses stub", ctn.Get("consumer"))
+}
+
+func TestBuilder_Remove(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "failing",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return nil, errors.New("expected error")
+			},
+		},
+		di.Def{
+			Name: "ok",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "ok", nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, b.Remove("failing"))
+	assert.Error(t, b.Remove("failing"))
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, ctn.Has("failing"))
+	assert.Equal(t, "ok", ctn.Get("ok"))
+}
+
+func TestBuilder_Decorate(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "router",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "router", nil
+			},
+		},
+		di.Def{
+			Name: "failing",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return nil, errors.New("expected error")
+			},
+		},
+		di.Def{
+			Name: "nobuild",
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = b.Decorate("router", func(obj interface{}) (interface{}, error) {
+		return "wrapped(" + obj.(string) + ")", nil
+	})
+	assert.NoError(t, err)
+
+	err = b.Decorate("router", func(obj interface{}) (interface{}, error) {
+		return "logged(" + obj.(string) + ")", nil
+	})
+	assert.NoError(t, err)
+
+	decorated := false
+	err = b.Decorate("failing", func(obj interface{}) (interface{}, error) {
+		decorated = true
+		return obj, nil
+	})
+	assert.NoError(t, err)
+
+	assert.Error(t, b.Decorate("unknown", func(obj interface{}) (interface{}, error) {
+		return obj, nil
+	}))
+	assert.Error(t, b.Decorate("nobuild", func(obj interface{}) (interface{}, error) {
+		return obj, nil
+	}))
+	assert.NoError(t, b.Remove("nobuild"))
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "logged(wrapped(router))", ctn.Get("router"))
+
+	_, err = ctn.SafeGet("failing")
+	assert.Error(t, err)
+	assert.False(t, decorated)
+}
+
+func TestBuilder_BuildOnly(t *testing.T) {
+	b := &di.Builder{}
+
+	var built []string
+	def := func(name string, deps ...string) di.Def {
+		return di.Def{
+			Name: name,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				for _, dep := range deps {
+					ctn.Get(dep)
+				}
+				built = append(built, name)
+				return name, nil
+			},
+		}
+	}
+
+	err := b.Add(def("config"), def("router", "config"), def("mongo", "config"), def("cmd", "mongo"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.BuildOnly("cmd")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"config", "mongo", "cmd"}, built)
+
+	assert.Equal(t, "router", ctn.Get("router"))
+	assert.Equal(t, []string{"config", "mongo", "cmd", "router"}, built)
+
+	_, err = (&di.Builder{}).BuildOnly("unknown")
+	assert.Error(t, err)
+}
@@ -1,18 +1,113 @@
 package di
 
 import (
+	"context"
 	"errors"
 	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Container is a dependency container
-type Container struct {
+// checkTimeoutDft is a default dependency check timeout
+const checkTimeoutDft = 3 * time.Second
+
+// CycleError is returned when definitions depend on each other in a cycle
+type CycleError struct {
+	// Path is a dependencies path, starting and ending with the same name
+	Path []string
+}
+
+// Error as a string
+func (e *CycleError) Error() string {
+	return "[bubucore.di] dependency cycle detected: " + strings.Join(e.Path, " -> ")
+}
+
+// newContainer creates an empty Container instance
+func newContainer() *Container {
+	return &Container{
+		store: newStore(nil),
+	}
+}
+
+// newStore creates an empty store instance
+func newStore(parent *store) *store {
+	return &store{
+		parent:  parent,
+		defs:    make(DefsMap),
+		deps:    make(map[string][]string),
+		flights: make(map[string]*flight),
+		waits:   make(map[string]string),
+	}
+}
+
+// store is a Container's shared state
+type store struct {
+	mu sync.RWMutex
+
+	// parent is a parent store of the SubContainer, nil for the root one
+	parent *store
+
+	// defs contains registered definitions for the root store,
+	// and request-scoped definitions resolved by the SubContainer
 	defs DefsMap
+
+	// built is a list of dependencies names in the build order.
+	// Every dependency is built after the dependencies it uses,
+	// so the list is topologically sorted.
+	built []string
+
+	// transient contains transient objects built by this store to close them
+	transient []Def
+
+	// deps contains names of the dependencies pulled by each definition's Build
+	deps map[string][]string
+
+	// flights contains builds in progress
+	flights map[string]*flight
+
+	// waits contains names of the builds in progress each build in progress waits for
+	waits map[string]string
+}
+
+// root returns the root store
+func (s *store) root() *store {
+	if s.parent == nil {
+		return s
+	}
+	return s.parent.root()
+}
+
+// flight is a definition build in progress
+type flight struct {
+	done chan struct{}
+	obj  interface{}
+	err  error
+}
+
+// Container is a dependency container.
+// Safe for concurrent use: each definition is built only once,
+// concurrent callers wait for the build in progress.
+// The CycleError is returned instead if that build waits for the caller's builds.
+type Container struct {
+	*store
+
+	// chain is a names path of definitions being built by this Container view
+	chain []string
+}
+
+// SubContainer creates a child Container to build ScopeRequest definitions with.
+// Singletons are shared with the parent Container.
+// Call Close on the SubContainer to finalize its request-scoped and transient objects.
+func (c *Container) SubContainer() *Container {
+	return &Container{
+		store: newStore(c.store),
+	}
 }
 
 // Has checks if dependency is registered in Container
 func (c *Container) Has(name string) bool {
-	_, ok := c.defs[name]
+	_, ok := c.lookup(name)
 	return ok
 }
 
@@ -25,28 +120,118 @@ func (c *Container) Get(name string) interface{} {
 	return obj
 }
 
-// SafeGet returns built dependency
+// SafeGet returns built dependency.
+// Builds the dependency if it is not built yet, either it is lazy or
+// requested by another definition's Build before its turn.
 func (c *Container) SafeGet(name string) (interface{}, error) {
-	def, ok := c.defs[name]
+	def, ok := c.lookup(name)
 	if !ok {
 		return nil, errors.New("[bubucore.di] dependency is not registered: " + name)
 	}
-	if def.Lazy {
-		err := def.build(c)
-		if err != nil {
-			return nil, err
+
+	if len(c.chain) > 0 {
+		c.mu.Lock()
+		c.addDep(c.chain[len(c.chain)-1], name)
+		c.mu.Unlock()
+	}
+
+	for i, n := range c.chain {
+		if n == name {
+			path := append(append([]string{}, c.chain[i:]...), name)
+			return nil, &CycleError{Path: path}
 		}
-		c.defs[name] = def
 	}
-	return def.obj, nil
+
+	switch def.Scope {
+	case ScopeTransient:
+		return c.buildTransient(def)
+	case ScopeRequest:
+		if c.parent == nil {
+			return nil, errors.New("[bubucore.di] request-scoped dependency `" + name + "` requires a SubContainer")
+		}
+	default:
+		if c.parent != nil {
+			root := &Container{store: c.root(), chain: c.chain}
+			return root.SafeGet(name)
+		}
+	}
+
+	if def.built {
+		return def.obj, nil
+	}
+
+	return c.buildOnce(name)
 }
 
-// Close finalizes dependencies
-func (c *Container) Close() {
-	for _, def := range c.defs {
-		if !def.built {
+// Swap atomically replaces the built singleton object with the obj and returns the previous one.
+// The previous object is not closed, the definition's Close will receive the new one.
+func (c *Container) Swap(name string, obj interface{}) (interface{}, error) {
+	root := c.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	def, ok := root.defs[name]
+	if !ok {
+		return nil, errors.New("[bubucore.di] dependency is not registered: " + name)
+	}
+	if !def.built || def.Scope != ScopeSingleton {
+		return nil, errors.New("[bubucore.di] dependency `" + name + "` is not a built singleton")
+	}
+
+	prev := def.obj
+	def.obj = obj
+	root.defs[name] = def
+
+	return prev, nil
+}
+
+// Dependencies returns names of dependencies the definition's Build has pulled from the Container
+func (c *Container) Dependencies(name string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.deps[name]...)
+}
+
+// Check probes built dependencies which have Def.Check defined.
+// Returns check results by dependency name, nil value means the dependency is ready.
+func (c *Container) Check(ctx context.Context) map[string]error {
+	res := make(map[string]error)
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for _, def := range c.builtDefs() {
+		if def.Check == nil {
 			continue
 		}
+		wg.Add(1)
+		go func(def Def) {
+			defer wg.Done()
+			err := def.check(ctx)
+			mu.Lock()
+			res[def.Name] = err
+			mu.Unlock()
+		}(def)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// Close finalizes dependencies built by this Container in the reverse topological order,
+// so dependents are closed before their dependencies.
+// Transient objects are closed first.
+func (c *Container) Close() {
+	defs := c.builtDefs()
+
+	c.mu.Lock()
+	defs = append(defs, c.transient...)
+	c.built = nil
+	c.transient = nil
+	c.mu.Unlock()
+
+	for i := len(defs) - 1; i >= 0; i-- {
+		def := defs[i]
 		if def.Close == nil {
 			continue
 		}
@@ -56,3 +241,178 @@ func (c *Container) Close() {
 		}
 	}
 }
+
+// lookup finds the definition in this Container or in its parents
+func (c *Container) lookup(name string) (Def, bool) {
+	c.mu.RLock()
+	def, ok := c.defs[name]
+	c.mu.RUnlock()
+	if ok {
+		return def, true
+	}
+	if c.parent == nil {
+		return def, false
+	}
+
+	root := c.root()
+	root.mu.RLock()
+	def, ok = root.defs[name]
+	root.mu.RUnlock()
+	if !ok {
+		return def, false
+	}
+
+	if def.Scope == ScopeRequest {
+		c.mu.Lock()
+		if _, exists := c.defs[name]; !exists {
+			c.defs[name] = def
+		}
+		def = c.defs[name]
+		c.mu.Unlock()
+	}
+
+	return def, true
+}
+
+// buildOnce builds the definition. Concurrent calls for the same definition
+// wait for the first one to finish and share its result.
+func (c *Container) buildOnce(name string) (interface{}, error) {
+	c.mu.Lock()
+	def := c.defs[name]
+	if def.built {
+		c.mu.Unlock()
+		return def.obj, nil
+	}
+	if f, ok := c.flights[name]; ok {
+		if err := c.waitCycle(name); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		blocked := c.block(name)
+		c.mu.Unlock()
+
+		<-f.done
+
+		c.mu.Lock()
+		for _, n := range blocked {
+			delete(c.waits, n)
+		}
+		c.mu.Unlock()
+
+		return f.obj, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[name] = f
+	c.mu.Unlock()
+
+	f.err = def.build(c.view(name))
+	if f.err == nil {
+		f.obj = def.obj
+	}
+
+	c.mu.Lock()
+	if f.err == nil {
+		c.defs[name] = def
+		c.built = append(c.built, name)
+	}
+	delete(c.flights, name)
+	c.mu.Unlock()
+
+	close(f.done)
+
+	return f.obj, f.err
+}
+
+// waitCycle returns the CycleError if the build in progress waits, directly or through other builds,
+// for the builds of this Container view, so waiting for it would never end. Must be called under the lock.
+func (c *Container) waitCycle(name string) error {
+	path := []string{name}
+	for n := name; ; {
+		for i, own := range c.chain {
+			if own == n {
+				return &CycleError{Path: append(append([]string{}, c.chain[i:]...), path...)}
+			}
+		}
+		next, ok := c.waits[n]
+		if !ok {
+			return nil
+		}
+		path = append(path, next)
+		n = next
+	}
+}
+
+// block records the builds in progress of this Container view wait for the name build.
+// Returns the names recorded. Must be called under the lock.
+func (c *Container) block(name string) []string {
+	blocked := make([]string, 0, len(c.chain))
+	for _, n := range c.chain {
+		if _, ok := c.flights[n]; ok {
+			c.waits[n] = name
+			blocked = append(blocked, n)
+		}
+	}
+	return blocked
+}
+
+// buildTransient builds new object of the definition.
+// The root Container builds the transient objects with Close for the singletons only,
+// as it would keep every object built to close them until the Container is closed.
+func (c *Container) buildTransient(def Def) (interface{}, error) {
+	if def.Close != nil && c.parent == nil && !c.buildingSingleton() {
+		return nil, errors.New("[bubucore.di] transient dependency `" + def.Name + "` with Close requires a SubContainer")
+	}
+	err := def.build(c.view(def.Name))
+	if err != nil {
+		return nil, err
+	}
+	if def.Close != nil {
+		c.mu.Lock()
+		c.transient = append(c.transient, def)
+		c.mu.Unlock()
+	}
+	return def.obj, nil
+}
+
+// buildingSingleton checks if the Container view is building a singleton
+func (c *Container) buildingSingleton() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, name := range c.chain {
+		if def, ok := c.defs[name]; ok && def.Scope == ScopeSingleton {
+			return true
+		}
+	}
+	return false
+}
+
+// builtDefs returns built definitions in the build order
+func (c *Container) builtDefs() []Def {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	defs := make([]Def, 0, len(c.built))
+	for _, name := range c.built {
+		defs = append(defs, c.defs[name])
+	}
+	return defs
+}
+
+// view returns Container view to build the named definition with
+func (c *Container) view(name string) *Container {
+	chain := make([]string, len(c.chain), len(c.chain)+1)
+	copy(chain, c.chain)
+	return &Container{
+		store: c.store,
+		chain: append(chain, name),
+	}
+}
+
+// addDep registers dependency of the definition. Must be called under the lock.
+func (c *Container) addDep(name string, dep string) {
+	for _, d := range c.deps[name] {
+		if d == dep {
+			return
+		}
+	}
+	c.deps[name] = append(c.deps[name], dep)
+}
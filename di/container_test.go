@@ -0,0 +1,599 @@
+package di_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/bubulearn/bubucore/metrics"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContainer_Close(t *testing.T) {
+	var closed []string
+	closeFn := func(name string) di.CloseFn {
+		return func(obj interface{}) error {
+			closed = append(closed, name)
+			return nil
+		}
+	}
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "lazy",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "lazy", nil
+			},
+			Close: closeFn("lazy"),
+		},
+		di.Def{
+			Name: "first",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "first", nil
+			},
+			Close: closeFn("first"),
+		},
+		di.Def{
+			Name: "second",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.Get("lazy"), nil
+			},
+			Close: closeFn("second"),
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn.Close()
+	assert.Equal(t, []string{"second", "lazy", "first"}, closed)
+
+	ctn.Close()
+	assert.Len(t, closed, 3)
+}
+
+func TestContainer_Check(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "ok",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "ok", nil
+			},
+			Check: func(ctx context.Context, obj interface{}) error {
+				return nil
+			},
+		},
+		di.Def{
+			Name: "fail",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "fail", nil
+			},
+			Check: func(ctx context.Context, obj interface{}) error {
+				return errors.New("expected error")
+			},
+		},
+		di.Def{
+			Name: "slow",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "slow", nil
+			},
+			Check: func(ctx context.Context, obj interface{}) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			CheckTimeout: 10 * time.Millisecond,
+		},
+		di.Def{
+			Name: "lazy",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "lazy", nil
+			},
+			Check: func(ctx context.Context, obj interface{}) error {
+				return errors.New("unexpected check")
+			},
+		},
+		di.Def{
+			Name: "unchecked",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "unchecked", nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	res := ctn.Check(context.Background())
+	assert.Len(t, res, 3)
+	assert.NoError(t, res["ok"])
+	assert.Error(t, res["fail"])
+	assert.Error(t, res["slow"])
+}
+
+func TestContainer_Dependencies(t *testing.T) {
+	var closed []string
+	closeFn := func(name string) di.CloseFn {
+		return func(obj interface{}) error {
+			closed = append(closed, name)
+			return nil
+		}
+	}
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "users",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "users:" + ctn.Get("redis").(string), nil
+			},
+			Close: closeFn("users"),
+		},
+		di.Def{
+			Name: "redis",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "redis:" + ctn.Get("config").(string), nil
+			},
+			Close: closeFn("redis"),
+		},
+		di.Def{
+			Name: "config",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "config", nil
+			},
+			Close: closeFn("config"),
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "users:redis:config", ctn.Get("users"))
+	assert.Equal(t, []string{"redis"}, ctn.Dependencies("users"))
+	assert.Equal(t, []string{"config"}, ctn.Dependencies("redis"))
+	assert.Empty(t, ctn.Dependencies("config"))
+
+	ctn.Close()
+	assert.Equal(t, []string{"users", "redis", "config"}, closed)
+}
+
+func TestContainer_Cycle(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "a",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.Get("b"), nil
+			},
+		},
+		di.Def{
+			Name: "b",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.Get("c"), nil
+			},
+		},
+		di.Def{
+			Name: "c",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.Get("a"), nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = b.Build()
+	cycleErr := &di.CycleError{}
+	if assert.ErrorAs(t, err, &cycleErr) {
+		assert.Equal(t, []string{"a", "b", "c", "a"}, cycleErr.Path)
+		assert.Contains(t, err.Error(), "a -> b -> c -> a")
+	}
+
+	b = &di.Builder{}
+	err = b.Add(di.Def{
+		Name: "self",
+		Lazy: true,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return ctn.SafeGet("self")
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = ctn.SafeGet("self")
+	assert.ErrorAs(t, err, &cycleErr)
+}
+
+func TestContainer_ConcurrentCycle(t *testing.T) {
+	started := &sync.WaitGroup{}
+	started.Add(2)
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "x",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				started.Done()
+				started.Wait()
+				return ctn.SafeGet("y")
+			},
+		},
+		di.Def{
+			Name: "y",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				started.Done()
+				started.Wait()
+				return ctn.SafeGet("x")
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errs := make(chan error, 2)
+	for _, name := range []string{"x", "y"} {
+		go func(name string) {
+			_, err := ctn.SafeGet(name)
+			errs <- err
+		}(name)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-errs:
+			cycleErr := &di.CycleError{}
+			if assert.ErrorAs(t, err, &cycleErr) {
+				assert.Len(t, cycleErr.Path, 3)
+				assert.Equal(t, cycleErr.Path[0], cycleErr.Path[2])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("concurrent builds of the cycle are deadlocked")
+		}
+	}
+}
+
+func TestContainer_ConcurrentGet(t *testing.T) {
+	var builds int32
+
+	b := &di.Builder{}
+	for i := 0; i < 10; i++ {
+		name := "lazy" + strconv.Itoa(i)
+		dep := "lazy" + strconv.Itoa(i+1)
+		last := i == 9
+		err := b.Add(di.Def{
+			Name: name,
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				atomic.AddInt32(&builds, 1)
+				time.Sleep(time.Millisecond)
+				if last {
+					return name, nil
+				}
+				return name + ":" + ctn.Get(dep).(string), nil
+			},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := "lazy" + strconv.Itoa(i%10)
+			obj, err := ctn.SafeGet(name)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(obj.(string), name))
+			assert.True(t, ctn.Has(name))
+			_ = ctn.Dependencies(name)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), atomic.LoadInt32(&builds))
+	assert.Equal(t, "lazy0:lazy1:lazy2:lazy3:lazy4:lazy5:lazy6:lazy7:lazy8:lazy9", ctn.Get("lazy0"))
+
+	ctn.Close()
+}
+
+func TestContainer_ConcurrentGetError(t *testing.T) {
+	var builds int32
+
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name: "failing",
+		Lazy: true,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			atomic.AddInt32(&builds, 1)
+			time.Sleep(10 * time.Millisecond)
+			return nil, errors.New("expected error")
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ctn.SafeGet("failing")
+			assert.Error(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Less(t, atomic.LoadInt32(&builds), int32(20))
+}
+
+func TestContainer_SubContainer(t *testing.T) {
+	var closed []string
+	var builds int32
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "singleton",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				atomic.AddInt32(&builds, 1)
+				return &struct{ name string }{"singleton"}, nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, "singleton")
+				return nil
+			},
+		},
+		di.Def{
+			Name:  "request",
+			Scope: di.ScopeRequest,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				_ = ctn.Get("singleton")
+				return &struct{ name string }{"request"}, nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, "request")
+				return nil
+			},
+		},
+		di.Def{
+			Name:  "transient",
+			Scope: di.ScopeTransient,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				_ = ctn.Get("request")
+				return &struct{ name string }{"transient"}, nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, "transient")
+				return nil
+			},
+		},
+		di.Def{
+			Name: "captive",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.Get("request"), nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = ctn.SafeGet("request")
+	assert.Error(t, err)
+
+	sub1 := ctn.SubContainer()
+	sub2 := ctn.SubContainer()
+
+	assert.True(t, sub1.Has("singleton"))
+	assert.True(t, sub1.Has("request"))
+	assert.False(t, sub1.Has("unknown"))
+
+	assert.Same(t, ctn.Get("singleton"), sub1.Get("singleton"))
+	assert.Same(t, sub1.Get("singleton"), sub2.Get("singleton"))
+
+	assert.Same(t, sub1.Get("request"), sub1.Get("request"))
+	assert.NotSame(t, sub1.Get("request"), sub2.Get("request"))
+
+	assert.NotSame(t, sub1.Get("transient"), sub1.Get("transient"))
+
+	_, err = sub1.SafeGet("captive")
+	assert.Error(t, err)
+
+	sub1.Close()
+	assert.Equal(t, []string{"transient", "transient", "request"}, closed)
+
+	closed = nil
+	sub2.Close()
+	assert.Equal(t, []string{"request"}, closed)
+
+	closed = nil
+	ctn.Close()
+	assert.Equal(t, []string{"singleton"}, closed)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&builds))
+}
+
+func TestContainer_Transient(t *testing.T) {
+	var closed []string
+	var builds int32
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name:  "conn",
+			Scope: di.ScopeTransient,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				atomic.AddInt32(&builds, 1)
+				return &struct{ name string }{"conn"}, nil
+			},
+			Close: func(obj interface{}) error {
+				closed = append(closed, "conn")
+				return nil
+			},
+		},
+		di.Def{
+			Name:  "value",
+			Scope: di.ScopeTransient,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return &struct{ name string }{"value"}, nil
+			},
+		},
+		di.Def{
+			Name:  "wrapper",
+			Scope: di.ScopeTransient,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.SafeGet("conn")
+			},
+		},
+		di.Def{
+			Name: "singleton",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return ctn.SafeGet("conn")
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&builds))
+
+	// the root Container doesn't build the transient objects it would have to keep until closed
+	for i := 0; i < 10; i++ {
+		_, err = ctn.SafeGet("conn")
+		assert.Error(t, err)
+		_, err = ctn.SafeGet("wrapper")
+		assert.Error(t, err)
+		assert.NotSame(t, ctn.Get("value"), ctn.Get("value"))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&builds))
+
+	sub := ctn.SubContainer()
+	for i := 0; i < 3; i++ {
+		_, err = sub.SafeGet("conn")
+		assert.NoError(t, err)
+	}
+	sub.Close()
+	assert.Equal(t, []string{"conn", "conn", "conn"}, closed)
+
+	closed = nil
+	ctn.Close()
+	assert.Equal(t, []string{"conn"}, closed)
+}
+
+func TestContainer_Swap(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(
+		di.Def{
+			Name: "config",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "v1", nil
+			},
+		},
+		di.Def{
+			Name: "lazy",
+			Lazy: true,
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return "lazy", nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	prev, err := ctn.SubContainer().Swap("config", "v2")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", prev)
+	assert.Equal(t, "v2", ctn.Get("config"))
+
+	_, err = ctn.Swap("lazy", "v2")
+	assert.Error(t, err)
+
+	_, err = ctn.Swap("unknown", "v2")
+	assert.Error(t, err)
+}
+
+func TestContainer_BuildMetric(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name: "metered",
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = b.Build()
+	assert.NoError(t, err)
+
+	h := metrics.Default.Histogram("bubu_di_build_duration_seconds", "", nil, "name")
+	assert.Equal(t, uint64(1), h.Count("metered"))
+}
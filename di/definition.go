@@ -1,6 +1,21 @@
 package di
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bubulearn/bubucore/metrics"
+	"time"
+)
+
+// buildMetric is a definitions build duration histogram, see metrics.Default.
+// The duration includes builds of the dependencies pulled by the definition's Build.
+var buildMetric = metrics.Default.Histogram(
+	"bubu_di_build_duration_seconds",
+	"DI definitions build duration, including their dependencies builds.",
+	nil,
+	"name",
+)
 
 // DefsMap is a dependencies definitions map
 type DefsMap map[string]Def
@@ -14,14 +29,43 @@ type BuildFn func(ctn *Container) (interface{}, error)
 // CloseFn is a dependency close function
 type CloseFn func(obj interface{}) error
 
+// DecorateFn is a dependency decoration function, see Builder.Decorate
+type DecorateFn func(obj interface{}) (interface{}, error)
+
+// CheckFn is a dependency readiness check function
+type CheckFn func(ctx context.Context, obj interface{}) error
+
+// Scope is a dependency lifetime
+type Scope int
+
+// Dependencies scopes
+const (
+	// ScopeSingleton dependency is built once per Container
+	ScopeSingleton Scope = iota
+
+	// ScopeRequest dependency is built once per SubContainer, e. g. per http request,
+	// and is closed on the SubContainer close
+	ScopeRequest
+
+	// ScopeTransient dependency is built on every Container.Get call,
+	// built objects are closed on the Container close.
+	// Dependencies with Close are built by the SubContainer or for the singletons only,
+	// the root Container returns an error otherwise.
+	ScopeTransient
+)
+
 // Def is a dependency definition
 type Def struct {
 	// Name is a dependency name
 	Name string
 
 	// Lazy is a flag. If true, Build will be executed only on Container.Get() call.
+	// Non-singleton dependencies are always lazy.
 	Lazy bool
 
+	// Scope is a dependency lifetime, ScopeSingleton by default
+	Scope Scope
+
 	// Validate validates dependency definition on add
 	Validate ValidateFn
 
@@ -31,12 +75,20 @@ type Def struct {
 	// Close finalizes dependency object
 	Close CloseFn
 
+	// Check probes if dependency object is ready to serve, e. g. pings the connection.
+	// Called on demand by Container.Check for built dependencies only.
+	Check CheckFn
+
+	// CheckTimeout is a Check timeout. Default is 3 seconds.
+	CheckTimeout time.Duration
+
 	obj   interface{}
 	built bool
 }
 
-// build builds dependency's object
-func (d *Def) build(ctn *Container) error {
+// build builds dependency's object.
+// Panics of the Build function, e. g. of the failed Container.Get calls, are returned as errors.
+func (d *Def) build(ctn *Container) (err error) {
 	if d.built {
 		return nil
 	}
@@ -46,7 +98,41 @@ func (d *Def) build(ctn *Container) error {
 		return errors.New("[bubucore.di] definition `" + d.Name + "`: Build function is not defined")
 	}
 
-	var err error
+	start := time.Now()
+	defer func() {
+		buildMetric.Observe(time.Since(start).Seconds(), d.Name)
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("[bubucore.di] definition `%s`: build panicked: %v", d.Name, r)
+			}
+		}
+	}()
+
 	d.obj, err = d.Build(ctn)
 	return err
 }
+
+// check runs dependency's Check function with timeout
+func (d *Def) check(ctx context.Context) error {
+	timeout := d.CheckTimeout
+	if timeout <= 0 {
+		timeout = checkTimeoutDft
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Check(ctx, d.obj)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("[bubucore.di] definition `" + d.Name + "`: check interrupted: " + ctx.Err().Error())
+	}
+}
@@ -0,0 +1,80 @@
+package di
+
+import (
+	"fmt"
+)
+
+// TypeError is returned when dependency object has unexpected type
+type TypeError struct {
+	// Name is a dependency name
+	Name string
+
+	// Expected is an expected type name
+	Expected string
+
+	// Actual is an actual object type name
+	Actual string
+}
+
+// Error as a string
+func (e *TypeError) Error() string {
+	return "[bubucore.di] dependency `" + e.Name + "` is of type `" + e.Actual + "`, expected `" + e.Expected + "`"
+}
+
+// Get returns typed dependency from the Container.
+// Returns TypeError if the dependency object is not of type T.
+// Nil object is returned as a zero value of T.
+func Get[T any](ctn *Container, name string) (T, error) {
+	var zero T
+
+	obj, err := ctn.SafeGet(name)
+	if err != nil {
+		return zero, err
+	}
+	if obj == nil {
+		return zero, nil
+	}
+
+	typed, ok := obj.(T)
+	if !ok {
+		return zero, &TypeError{
+			Name:     name,
+			Expected: fmt.Sprintf("%T", &zero)[1:],
+			Actual:   fmt.Sprintf("%T", obj),
+		}
+	}
+
+	return typed, nil
+}
+
+// MustGet returns typed dependency from the Container. Panics on error.
+func MustGet[T any](ctn *Container, name string) T {
+	obj, err := Get[T](ctn, name)
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// Provide creates a singleton definition with the typed Build function
+func Provide[T any](name string, build func(ctn *Container) (T, error)) Def {
+	return Def{
+		Name: name,
+		Build: func(ctn *Container) (interface{}, error) {
+			return build(ctn)
+		},
+	}
+}
+
+// ProvideWithClose creates a singleton definition with the typed Build and Close functions
+func ProvideWithClose[T any](name string, build func(ctn *Container) (T, error), closeFn func(obj T) error) Def {
+	def := Provide(name, build)
+	def.Close = func(obj interface{}) error {
+		typed, ok := obj.(T)
+		if !ok {
+			return nil
+		}
+		return closeFn(typed)
+	}
+	return def
+}
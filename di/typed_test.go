@@ -0,0 +1,74 @@
+package di_test
+
+import (
+	"github.com/bubulearn/bubucore/di"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type typedTestObj struct {
+	Value string
+}
+
+func TestGet(t *testing.T) {
+	closed := false
+
+	b := &di.Builder{}
+	err := b.Add(
+		di.Provide("obj", func(ctn *di.Container) (*typedTestObj, error) {
+			return &typedTestObj{Value: "test"}, nil
+		}),
+		di.ProvideWithClose("closable", func(ctn *di.Container) (*typedTestObj, error) {
+			return &typedTestObj{Value: di.MustGet[*typedTestObj](ctn, "obj").Value + "2"}, nil
+		}, func(obj *typedTestObj) error {
+			closed = true
+			return nil
+		}),
+		di.Def{
+			Name: "nil",
+			Build: func(ctn *di.Container) (interface{}, error) {
+				return nil, nil
+			},
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	obj, err := di.Get[*typedTestObj](ctn, "obj")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", obj.Value)
+	assert.Equal(t, "test2", di.MustGet[*typedTestObj](ctn, "closable").Value)
+
+	nilObj, err := di.Get[*typedTestObj](ctn, "nil")
+	assert.NoError(t, err)
+	assert.Nil(t, nilObj)
+
+	_, err = di.Get[*typedTestObj](ctn, "unknown")
+	assert.Error(t, err)
+
+	_, err = di.Get[string](ctn, "obj")
+	typeErr := &di.TypeError{}
+	if assert.ErrorAs(t, err, &typeErr) {
+		assert.Equal(t, "obj", typeErr.Name)
+		assert.Equal(t, "string", typeErr.Expected)
+		assert.Equal(t, "*di_test.typedTestObj", typeErr.Actual)
+	}
+
+	_, err = di.Get[error](ctn, "obj")
+	if assert.ErrorAs(t, err, &typeErr) {
+		assert.Equal(t, "error", typeErr.Expected)
+	}
+
+	assert.Panics(t, func() {
+		di.MustGet[int](ctn, "obj")
+	})
+
+	ctn.Close()
+	assert.True(t, closed)
+}
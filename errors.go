@@ -12,7 +12,9 @@ var (
 	ErrRoleNotAllowed   = NewError(http.StatusForbidden, "unexpected user role")
 	ErrTokenInvalid     = NewError(http.StatusUnauthorized, "token is invalid")
 	ErrTokenExpired     = NewError(http.StatusUnauthorized, "token is expired")
+	ErrTokenReused      = NewError(http.StatusUnauthorized, "token is reused")
 	ErrTokenUnsupported = NewError(http.StatusUnprocessableEntity, "unsupported sign method")
+	ErrKeysUnavailable  = NewError(http.StatusServiceUnavailable, "token keys are unavailable")
 	ErrNotFound         = NewError(http.StatusNotFound, "not found")
 )
 
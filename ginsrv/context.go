@@ -1,6 +1,7 @@
 package ginsrv
 
 import (
+	"context"
 	"github.com/bubulearn/bubucore"
 	"github.com/bubulearn/bubucore/di"
 	"github.com/bubulearn/bubucore/i18n"
@@ -28,6 +29,15 @@ func (h *ContextHandler) C() *gin.Context {
 	return h.Context
 }
 
+// Ctx returns the request's context.Context, cancelled when the client goes away.
+// Pass it to the context-aware clients and DAOs methods.
+func (h *ContextHandler) Ctx() context.Context {
+	if h.Request == nil {
+		return context.Background()
+	}
+	return h.Request.Context()
+}
+
 // GetContainer returns di.Container instance
 func (h *ContextHandler) GetContainer() *di.Container {
 	ctn, ok := h.Get(KeyDIContainer)
@@ -39,7 +49,7 @@ func (h *ContextHandler) GetContainer() *di.Container {
 
 // GetI18nSource returns i18n texts source from the container
 func (h *ContextHandler) GetI18nSource() *i18n.TextsSource {
-	return h.GetContainer().Get("bubu_i18n").(*i18n.TextsSource)
+	return di.MustGet[*i18n.TextsSource](h.GetContainer(), i18n.DISourceName)
 }
 
 // GetAccessClaims returns AccessTokenClaims from the current gin context
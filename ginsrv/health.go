@@ -0,0 +1,76 @@
+package ginsrv
+
+import (
+	"github.com/bubulearn/bubucore"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Health statuses
+const (
+	HealthStatusOk   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthResp is a health endpoints response
+type HealthResp struct {
+	Status string                      `json:"status" example:"ok"`
+	Checks map[string]*HealthCheckResp `json:"checks,omitempty"`
+}
+
+// HealthCheckResp is a single dependency check result.
+// The check errors are logged only, as the endpoint is not authenticated.
+type HealthCheckResp struct {
+	Status string `json:"status" example:"fail"`
+}
+
+// HealthController serves liveness, readiness and service info endpoints
+type HealthController struct {
+	ControllerDft
+}
+
+// Init initializes the controller's actions
+func (c *HealthController) Init(group *gin.RouterGroup) {
+	group.GET("/health/live", c.Live)
+	group.GET("/health/ready", c.Ready)
+	group.GET("/info", c.Info)
+}
+
+// Live reports the service process is alive
+func (c *HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, &HealthResp{Status: HealthStatusOk})
+}
+
+// Ready probes the DI container dependencies and reports their statuses.
+// Responds with 503 status if any of dependencies is not ready.
+func (c *HealthController) Ready(ctx *gin.Context) {
+	resp := &HealthResp{
+		Status: HealthStatusOk,
+		Checks: make(map[string]*HealthCheckResp),
+	}
+
+	if c.GetContainer() != nil {
+		for name, err := range c.GetContainer().Check(ctx.Request.Context()) {
+			check := &HealthCheckResp{Status: HealthStatusOk}
+			if err != nil {
+				check.Status = HealthStatusFail
+				log.Warn("dependency check failed: ", name, ": ", err)
+				resp.Status = HealthStatusFail
+			}
+			resp.Checks[name] = check
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status != HealthStatusOk {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.JSON(status, resp)
+}
+
+// Info responds with the service info
+func (c *HealthController) Info(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, bubucore.GetServiceInfo())
+}
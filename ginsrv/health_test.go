@@ -0,0 +1,78 @@
+package ginsrv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestHealthController(t *testing.T) {
+	var checkErr error
+
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name: "dep",
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return "dep", nil
+		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			return checkErr
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	router := gin.New()
+	c := &HealthController{}
+	c.SetContainer(ctn)
+	c.Init(router.Group("/api"))
+
+	var body string
+	do := func(path string) (int, *HealthResp) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		body = w.Body.String()
+		resp := &HealthResp{}
+		_ = jsoniter.Unmarshal(w.Body.Bytes(), resp)
+		return w.Code, resp
+	}
+
+	code, resp := do("/api/health/live")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOk, resp.Status)
+
+	code, resp = do("/api/health/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOk, resp.Status)
+	assert.Equal(t, HealthStatusOk, resp.Checks["dep"].Status)
+
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	// the check errors are logged, not exposed
+	checkErr = errors.New("connection refused")
+	code, resp = do("/api/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusFail, resp.Status)
+	assert.Equal(t, HealthStatusFail, resp.Checks["dep"].Status)
+	assert.NotContains(t, body, "connection refused")
+	assert.Contains(t, logs.String(), "connection refused")
+
+	code, _ = do("/api/info")
+	assert.Equal(t, http.StatusOK, code)
+}
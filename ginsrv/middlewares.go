@@ -5,7 +5,9 @@ import (
 	"github.com/bubulearn/bubucore"
 	"github.com/bubulearn/bubucore/di"
 	"github.com/bubulearn/bubucore/i18n"
+	"github.com/bubulearn/bubucore/metrics"
 	"github.com/bubulearn/bubucore/tokens"
+	"github.com/bubulearn/bubucore/tracing"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"io"
@@ -24,6 +26,9 @@ const (
 
 	// KeyDIContainer is a context key for a di.Container
 	KeyDIContainer = "BubuDIContainer"
+
+	// KeyRequestID is a context key for the request ID
+	KeyRequestID = "BubuRequestID"
 )
 
 // M returns Middlewares instance
@@ -41,10 +46,82 @@ var middlewares *Middlewares
 type Middlewares struct {
 }
 
-// SetDIContainer is a middleware to set app.App instance to the context
+// SetDIContainer is a middleware to set the request's di.SubContainer to the context.
+// The SubContainer is closed when the request is handled.
 func (m *Middlewares) SetDIContainer(ctn *di.Container) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Set(KeyDIContainer, ctn)
+		sub := ctn.SubContainer()
+		defer sub.Close()
+		c.Set(KeyDIContainer, sub)
+		c.Next()
+	}
+}
+
+// Tracing is a middleware to continue the trace of the incoming traceparent header, or to start new one.
+// Starts the server span, stores it and the request ID to the request's context.Context
+// and sets the request ID to the X-Request-ID response header. Use it as the first middleware.
+func (m *Middlewares) Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+
+		requestID := tracing.RequestIDFromContext(ctx)
+		if requestID == "" {
+			requestID = tracing.NewRequestID()
+			ctx = tracing.ContextWithRequestID(ctx, requestID)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := tracing.StartSpan(ctx, c.Request.Method+" "+route, tracing.SpanKindServer)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(KeyRequestID, requestID)
+		c.Header(tracing.HeaderRequestID, requestID)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("request_id", requestID)
+		if status >= 500 {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+	}
+}
+
+// Metrics is a middleware to count requests and observe their latency
+// by the route path, method and status, see metrics.Default.
+// Requests to unknown routes are counted with the `unmatched` path.
+func (m *Middlewares) Metrics() gin.HandlerFunc {
+	requests := metrics.Default.Counter(
+		"bubu_http_requests_total",
+		"HTTP requests handled.",
+		"method", "path", "status",
+	)
+	latency := metrics.Default.Histogram(
+		"bubu_http_request_duration_seconds",
+		"HTTP requests handling latency.",
+		nil,
+		"method", "path", "status",
+	)
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		requests.Inc(c.Request.Method, path, status)
+		latency.Observe(time.Since(start).Seconds(), c.Request.Method, path, status)
 	}
 }
 
@@ -213,7 +290,7 @@ func (m *Middlewares) LogBody() gin.HandlerFunc {
 		writer := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: ctx.Writer}
 		ctx.Writer = writer
 		ctx.Next()
-		logger := log.WithFields(log.Fields{
+		logger := log.WithContext(ctx.Request.Context()).WithFields(log.Fields{
 			bubucore.LogFieldType:   bubucore.LogTypeHTTPIO,
 			bubucore.LogFieldStatus: ctx.Writer.Status(),
 			bubucore.LogFieldPath:   ctx.FullPath(),
@@ -266,6 +343,14 @@ func (m *Middlewares) LogFormatter(param gin.LogFormatterParams) string {
 		data[bubucore.LogFieldLevel] = "info"
 	}
 
+	if sc, ok := tracing.SpanContextFromContext(param.Request.Context()); ok {
+		data[bubucore.LogFieldTraceID] = sc.TraceID.String()
+		data[bubucore.LogFieldSpanID] = sc.SpanID.String()
+	}
+	if id := tracing.RequestIDFromContext(param.Request.Context()); id != "" {
+		data[bubucore.LogFieldRequestID] = id
+	}
+
 	data[bubucore.LogFieldClientAppVersion] = param.Request.Header.Get("client_app_version")
 	data[bubucore.LogFieldClientAppPlatform] = param.Request.Header.Get("client_app_platform")
 
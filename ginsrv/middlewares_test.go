@@ -0,0 +1,187 @@
+package ginsrv
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/bubulearn/bubucore/i18n"
+	"github.com/bubulearn/bubucore/metrics"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/bubulearn/bubucore/tracing"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddlewares_SetDIContainer(t *testing.T) {
+	var built, closed int
+
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name:  "session",
+		Scope: di.ScopeRequest,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			built++
+			return built, nil
+		},
+		Close: func(obj interface{}) error {
+			closed++
+			return nil
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	router := gin.New()
+	router.Use(M().SetDIContainer(ctn))
+	router.GET("/", func(c *gin.Context) {
+		ctx := NewContextHandler(c)
+		first := ctx.GetContainer().Get("session")
+		assert.Equal(t, first, ctx.GetContainer().Get("session"))
+		assert.Equal(t, built-1, closed)
+		c.Status(http.StatusNoContent)
+	})
+
+	for i := 1; i <= 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, i, built)
+		assert.Equal(t, i, closed)
+	}
+}
+
+func TestMiddlewares_Tracing(t *testing.T) {
+	var traceID tracing.TraceID
+	var requestID string
+
+	router := gin.New()
+	router.Use(M().Tracing())
+	router.GET("/items/:id", func(c *gin.Context) {
+		ctx := NewContextHandler(c).Ctx()
+		sc, ok := tracing.SpanContextFromContext(ctx)
+		assert.True(t, ok)
+		traceID = sc.TraceID
+		requestID = tracing.RequestIDFromContext(ctx)
+		assert.Equal(t, requestID, c.GetString(KeyRequestID))
+		c.Status(http.StatusNoContent)
+	})
+
+	// new trace
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	assert.True(t, traceID.IsValid())
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, w.Header().Get(tracing.HeaderRequestID))
+
+	// continued trace
+	parent := tracing.SpanContext{TraceID: tracing.NewTraceID(), SpanID: tracing.NewSpanID(), Flags: tracing.FlagSampled}
+	req := httptest.NewRequest(http.MethodGet, "/items/2", nil)
+	req.Header.Set(tracing.HeaderTraceparent, parent.Traceparent())
+	req.Header.Set(tracing.HeaderRequestID, "req-42")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, parent.TraceID, traceID)
+	assert.Equal(t, "req-42", requestID)
+	assert.Equal(t, "req-42", w.Header().Get(tracing.HeaderRequestID))
+}
+
+func TestMiddlewares_Metrics(t *testing.T) {
+	router := gin.New()
+	router.Use(M().Metrics())
+	router.GET("/metrics-test/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	requests := metrics.Default.Counter("bubu_http_requests_total", "", "method", "path", "status")
+	latency := metrics.Default.Histogram("bubu_http_request_duration_seconds", "", nil, "method", "path", "status")
+	before := requests.Value(http.MethodGet, "/metrics-test/:id", "204")
+	beforeUnmatched := requests.Value(http.MethodGet, "unmatched", "404")
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, before+2, requests.Value(http.MethodGet, "/metrics-test/:id", "204"))
+	assert.Equal(t, beforeUnmatched+1, requests.Value(http.MethodGet, "unmatched", "404"))
+	assert.GreaterOrEqual(t, latency.Count(http.MethodGet, "/metrics-test/:id", "204"), uint64(2))
+}
+
+func TestMiddlewares_JWTAccess_KeyProvider(t *testing.T) {
+	defer tokens.SetKeyProvider(nil)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, err := tokens.NewKey("ed-1", priv, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	set, err := tokens.NewKeySet(key)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims := &tokens.AccessTokenClaims{Role: 10}
+	claims.UserID = "03a4e59c-fb22-4bfa-8739-8062bcdd2005"
+	claims.Id = "0bf97df4-6246-4809-bdf7-e8d993668283"
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	token := jwt.NewWithClaims(tokens.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(priv)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b := &di.Builder{}
+	err = b.Add(di.Def{
+		Name: i18n.DISourceName,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return i18n.Source, nil
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	router := gin.New()
+	router.Use(M().SetDIContainer(ctn))
+	router.GET("/", M().JWTAccess(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tokens.SetKeyProvider(set)
+	assert.Equal(t, http.StatusNoContent, request())
+
+	// JWKS is unavailable
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer jwks.Close()
+	tokens.SetKeyProvider(tokens.NewKeySource(jwks.URL, tokens.KeySourceOptions{}))
+	assert.Equal(t, http.StatusServiceUnavailable, request())
+
+	tokens.SetKeyProvider(nil)
+	assert.Equal(t, http.StatusUnauthorized, request())
+}
@@ -13,10 +13,16 @@ func GetDefaultRouter() *gin.Engine {
 
 	router := gin.New()
 
+	// Trace context and request ID
+	router.Use(M().Tracing())
+
+	// Requests metrics
+	router.Use(M().Metrics())
+
 	// Recover panics with formatted log
 	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
 		ctx := NewContextHandler(c)
-		log.Error(recovered)
+		log.WithContext(c.Request.Context()).Error(recovered)
 		if s, ok := recovered.(string); ok {
 			ctx.ErrS(s, http.StatusInternalServerError)
 		}
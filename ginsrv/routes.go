@@ -0,0 +1,272 @@
+package ginsrv
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Route auth requirements
+const (
+	RouteAuthRequired = "required"
+	RouteAuthOptional = "optional"
+)
+
+// middlewaresFuncPrefix is a name prefix of the Middlewares functions
+const middlewaresFuncPrefix = "ginsrv.(*Middlewares)."
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method  string `json:"method" example:"GET"`
+	Path    string `json:"path" example:"/api/v1/users/:id"`
+	Handler string `json:"handler" example:"main.(*UsersController).Get-fm"`
+
+	// Auth is an access token requirement, RouteAuthRequired or RouteAuthOptional, empty if not checked
+	Auth string `json:"auth,omitempty" example:"required"`
+
+	// Roles are the user role requirements, e. g. `>= 1000`
+	Roles []string `json:"roles,omitempty" example:">= 1000"`
+
+	// Middlewares are names of the bubucore middlewares guarding the route
+	Middlewares []string `json:"middlewares,omitempty" example:"JWTAccess,RequireRoleHigher"`
+}
+
+// NewRoutes creates new Routes instance
+func NewRoutes() *Routes {
+	return &Routes{
+		routes: make(map[string]*RouteInfo),
+	}
+}
+
+// Routes is a registry of the routes metadata, recorded by the RouteGroup
+type Routes struct {
+	mu     sync.RWMutex
+	routes map[string]*RouteInfo
+}
+
+// Group wraps the gin group to record the routes registered through it
+func (r *Routes) Group(group *gin.RouterGroup) *RouteGroup {
+	return &RouteGroup{
+		group:  group,
+		routes: r,
+	}
+}
+
+// Add records the route metadata
+func (r *Routes) Add(info *RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[info.Method+" "+info.Path] = info
+}
+
+// List returns the engine's routes sorted by path, with metadata for the recorded ones
+func (r *Routes) List(engine *gin.Engine) []*RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]*RouteInfo, 0)
+	for _, route := range engine.Routes() {
+		info, ok := r.routes[route.Method+" "+route.Path]
+		if !ok {
+			info = &RouteInfo{
+				Method:  route.Method,
+				Path:    route.Path,
+				Handler: route.Handler,
+			}
+		}
+		res = append(res, info)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path == res[j].Path {
+			return res[i].Method < res[j].Method
+		}
+		return res[i].Path < res[j].Path
+	})
+
+	return res
+}
+
+// Handler returns the handler responding with the engine's routes list
+func (r *Routes) Handler(engine *gin.Engine) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, r.List(engine))
+	}
+}
+
+// RouteGroup is a gin.RouterGroup wrapper recording the routes metadata to the Routes.
+// Use its Middlewares methods, e. g. JWTAccess or RequireRoleHigher, to record the requirements.
+type RouteGroup struct {
+	group  *gin.RouterGroup
+	routes *Routes
+
+	auth        string
+	roles       []string
+	middlewares []string
+}
+
+// RouterGroup returns the wrapped gin group
+func (g *RouteGroup) RouterGroup() *gin.RouterGroup {
+	return g.group
+}
+
+// Group creates a new RouteGroup, inheriting the group's requirements
+func (g *RouteGroup) Group(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	sub := &RouteGroup{
+		group:       g.group.Group(relativePath),
+		routes:      g.routes,
+		auth:        g.auth,
+		roles:       append([]string{}, g.roles...),
+		middlewares: append([]string{}, g.middlewares...),
+	}
+	return sub.Use(handlers...)
+}
+
+// Route creates a RouteGroup with the group's path and requirements, for the requirements of a single route, e. g.
+// g.Route().JWTAccess().RequireRole(100).GET("/lessons", handler)
+func (g *RouteGroup) Route() *RouteGroup {
+	return g.Group("")
+}
+
+// Use adds middlewares to the group
+func (g *RouteGroup) Use(handlers ...gin.HandlerFunc) *RouteGroup {
+	if len(handlers) == 0 {
+		return g
+	}
+	g.group.Use(handlers...)
+	for _, h := range handlers {
+		g.addMiddleware(h)
+	}
+	return g
+}
+
+// JWTAccess adds the Middlewares.JWTAccess to the group
+func (g *RouteGroup) JWTAccess() *RouteGroup {
+	return g.Use(M().JWTAccess())
+}
+
+// NonRequiredJWTAccess adds the Middlewares.NonRequiredJWTAccess to the group
+func (g *RouteGroup) NonRequiredJWTAccess() *RouteGroup {
+	return g.Use(M().NonRequiredJWTAccess())
+}
+
+// RequireRole adds the Middlewares.RequireRole to the group
+func (g *RouteGroup) RequireRole(role int) *RouteGroup {
+	g.roles = append(g.roles, "= "+strconv.Itoa(role))
+	return g.Use(M().RequireRole(role))
+}
+
+// RequireRoleIn adds the Middlewares.RequireRoleIn to the group
+func (g *RouteGroup) RequireRoleIn(roles ...int) *RouteGroup {
+	list := make([]string, len(roles))
+	for i, role := range roles {
+		list[i] = strconv.Itoa(role)
+	}
+	g.roles = append(g.roles, "in "+strings.Join(list, ", "))
+	return g.Use(M().RequireRoleIn(roles...))
+}
+
+// RequireRoleHigher adds the Middlewares.RequireRoleHigher to the group
+func (g *RouteGroup) RequireRoleHigher(role int) *RouteGroup {
+	g.roles = append(g.roles, ">= "+strconv.Itoa(role))
+	return g.Use(M().RequireRoleHigher(role))
+}
+
+// RequireStrictServiceAllowList adds the Middlewares.RequireStrictServiceAllowList to the group
+func (g *RouteGroup) RequireStrictServiceAllowList() *RouteGroup {
+	return g.Use(M().RequireStrictServiceAllowList())
+}
+
+// Handle registers the route and records its metadata.
+// The last handler is the route handler, others are route's middlewares.
+// Role requirements of the middlewares passed here are not recorded, use Route to set them.
+func (g *RouteGroup) Handle(method string, relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	g.group.Handle(method, relativePath, handlers...)
+
+	route := &RouteGroup{
+		auth:        g.auth,
+		roles:       append([]string{}, g.roles...),
+		middlewares: append([]string{}, g.middlewares...),
+	}
+	for _, h := range handlers[:len(handlers)-1] {
+		route.addMiddleware(h)
+	}
+
+	g.routes.Add(&RouteInfo{
+		Method:      method,
+		Path:        joinPaths(g.group.BasePath(), relativePath),
+		Handler:     funcName(handlers[len(handlers)-1]),
+		Auth:        route.auth,
+		Roles:       route.roles,
+		Middlewares: route.middlewares,
+	})
+
+	return g
+}
+
+// GET registers GET route, see Handle
+func (g *RouteGroup) GET(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	return g.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+// POST registers POST route, see Handle
+func (g *RouteGroup) POST(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	return g.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// PUT registers PUT route, see Handle
+func (g *RouteGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	return g.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+// PATCH registers PATCH route, see Handle
+func (g *RouteGroup) PATCH(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	return g.Handle(http.MethodPatch, relativePath, handlers...)
+}
+
+// DELETE registers DELETE route, see Handle
+func (g *RouteGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) *RouteGroup {
+	return g.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// addMiddleware records the bubucore middleware and its auth requirement
+func (g *RouteGroup) addMiddleware(h gin.HandlerFunc) {
+	name := funcName(h)
+	i := strings.Index(name, middlewaresFuncPrefix)
+	if i < 0 {
+		return
+	}
+	name = strings.TrimSuffix(name[i+len(middlewaresFuncPrefix):], ".func1")
+	g.middlewares = append(g.middlewares, name)
+
+	switch name {
+	case "JWTAccess":
+		g.auth = RouteAuthRequired
+	case "NonRequiredJWTAccess":
+		if g.auth == "" {
+			g.auth = RouteAuthOptional
+		}
+	}
+}
+
+// funcName returns the function name
+func funcName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+// joinPaths joins the group base path and the relative path like gin does
+func joinPaths(base string, relative string) string {
+	if relative == "" {
+		return base
+	}
+	res := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(relative, "/")
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(res, "/") {
+		res += "/"
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+package ginsrv
+
+import (
+	"encoding/json"
+	"github.com/bubulearn/bubucore/di"
+	"github.com/bubulearn/bubucore/i18n"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	b := &di.Builder{}
+	err := b.Add(di.Def{
+		Name: i18n.DISourceName,
+		Build: func(ctn *di.Container) (interface{}, error) {
+			return i18n.Source, nil
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctn, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	engine := gin.New()
+	engine.Use(M().SetDIContainer(ctn))
+	routes := NewRoutes()
+
+	handler := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+
+	api := routes.Group(engine.Group("/api/v1"))
+	api.Route().JWTAccess().RequireRole(100).GET("/lessons", handler)
+	api.GET("/public", handler)
+	api.GET("/optional", M().NonRequiredJWTAccess(), handler)
+
+	admin := api.Group("/admin").JWTAccess().RequireRoleHigher(1000)
+	admin.GET("/routes", routes.Handler(engine))
+	admin.Group("/teachers").RequireRoleIn(500, 1000).DELETE("/:id", handler)
+
+	engine.GET("/plain", handler)
+
+	list := routes.List(engine)
+	if !assert.Len(t, list, 6) {
+		return
+	}
+
+	byPath := make(map[string]*RouteInfo)
+	for _, r := range list {
+		byPath[r.Path] = r
+	}
+
+	r := byPath["/api/v1/public"]
+	assert.Equal(t, http.MethodGet, r.Method)
+	assert.Empty(t, r.Auth)
+	assert.Empty(t, r.Roles)
+	assert.Contains(t, r.Handler, "TestRoutes")
+
+	r = byPath["/api/v1/optional"]
+	assert.Equal(t, RouteAuthOptional, r.Auth)
+	assert.Equal(t, []string{"NonRequiredJWTAccess"}, r.Middlewares)
+
+	r = byPath["/api/v1/admin/routes"]
+	assert.Equal(t, RouteAuthRequired, r.Auth)
+	assert.Equal(t, []string{">= 1000"}, r.Roles)
+	assert.Equal(t, []string{"JWTAccess", "RequireRoleHigher"}, r.Middlewares)
+
+	r = byPath["/api/v1/admin/teachers/:id"]
+	assert.Equal(t, http.MethodDelete, r.Method)
+	assert.Equal(t, RouteAuthRequired, r.Auth)
+	assert.Equal(t, []string{">= 1000", "in 500, 1000"}, r.Roles)
+
+	// route scoped requirements
+	r = byPath["/api/v1/lessons"]
+	assert.Equal(t, RouteAuthRequired, r.Auth)
+	assert.Equal(t, []string{"= 100"}, r.Roles)
+	assert.Equal(t, []string{"JWTAccess", "RequireRole"}, r.Middlewares)
+
+	r = byPath["/plain"]
+	assert.Empty(t, r.Auth)
+	assert.Contains(t, r.Handler, "TestRoutes")
+
+	// the routes endpoint is protected
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/routes", nil)
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	routes.Handler(engine)(newTestContext(w))
+	var resp []*RouteInfo
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp)) {
+		assert.Len(t, resp, 6)
+	}
+}
+
+func newTestContext(w http.ResponseWriter) *gin.Context {
+	ctx, _ := gin.CreateTestContext(w)
+	return ctx
+}
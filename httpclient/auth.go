@@ -0,0 +1,40 @@
+package httpclient
+
+import (
+	"net/http"
+)
+
+// Auth is a request authentication strategy
+type Auth interface {
+	// Apply sets the request's authentication data
+	Apply(req *http.Request) error
+}
+
+// AuthFn is a function adapter for Auth
+type AuthFn func(req *http.Request) error
+
+// Apply calls the fn
+func (fn AuthFn) Apply(req *http.Request) error {
+	return fn(req)
+}
+
+// BearerAuth authenticates requests with the `Authorization: Bearer <token>` header
+func BearerAuth(token string) Auth {
+	return HeaderAuth("Authorization", "Bearer "+token)
+}
+
+// HeaderAuth authenticates requests with the custom header, e. g. API key
+func HeaderAuth(name string, value string) Auth {
+	return AuthFn(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
+
+// BasicAuth authenticates requests with the basic HTTP authentication
+func BasicAuth(username string, password string) Auth {
+	return AuthFn(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = BreakerState("closed")
+	BreakerOpen     = BreakerState("open")
+	BreakerHalfOpen = BreakerState("half-open")
+)
+
+// BreakerState is a circuit breaker state
+type BreakerState string
+
+// BreakerPolicy is a per-host circuit breaker policy.
+// The breaker opens after FailureThreshold consecutive failures (transport errors and 5xx responses)
+// and fails requests fast for the OpenTimeout. Then a single trial request is let through
+// to close the breaker on success.
+type BreakerPolicy struct {
+	// FailureThreshold is a number of consecutive failures to open the breaker, 0 disables the breaker
+	FailureThreshold int
+
+	// OpenTimeout is a time to fail requests fast before the trial request
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerPolicy returns the default BreakerPolicy
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// newBreaker creates a circuit breaker
+func newBreaker(policy BreakerPolicy) *breaker {
+	return &breaker{
+		policy: policy,
+		state:  BreakerClosed,
+	}
+}
+
+// breaker is a circuit breaker of a single host
+type breaker struct {
+	mu       sync.Mutex
+	policy   BreakerPolicy
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// State returns the breaker state
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.policy.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Allow checks if the request may be sent
+func (b *breaker) Allow() bool {
+	if b.policy.FailureThreshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Release lets the next trial request through without registering the result,
+// e. g. if the request is canceled by the caller
+func (b *breaker) Release() {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+// Report registers the request result
+func (b *breaker) Report(success bool) {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.policy.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
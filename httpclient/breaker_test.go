@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"context"
+	"github.com/bubulearn/bubucore"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Breaker(t *testing.T) {
+	var calls int32
+	var healthy int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		BaseURL: srv.URL,
+		Retry:   &RetryPolicy{MaxAttempts: 1},
+		Breaker: &BreakerPolicy{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond},
+	})
+	host := srv.Listener.Addr().String()
+
+	for i := 0; i < 2; i++ {
+		assert.Error(t, c.DoJSON(context.Background(), http.MethodGet, "/", nil, nil))
+	}
+	assert.Equal(t, BreakerOpen, c.BreakerStates()[host])
+	assert.Error(t, c.CheckBreakers())
+
+	err := c.DoJSON(context.Background(), http.MethodGet, "/", nil, nil)
+	if assert.IsType(t, &bubucore.Error{}, err) {
+		assert.Equal(t, http.StatusServiceUnavailable, err.(*bubucore.Error).Code)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// failed trial request opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, c.BreakerStates()[host])
+	assert.Error(t, c.DoJSON(context.Background(), http.MethodGet, "/", nil, nil))
+	assert.Equal(t, BreakerOpen, c.BreakerStates()[host])
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// successful trial request closes the breaker
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, c.DoJSON(context.Background(), http.MethodGet, "/", nil, nil))
+	assert.Equal(t, BreakerClosed, c.BreakerStates()[host])
+	assert.NoError(t, c.CheckBreakers())
+}
+
+func TestClient_BreakerCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		BaseURL: srv.URL,
+		Retry:   &RetryPolicy{MaxAttempts: 1},
+		Breaker: &BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute},
+	})
+	host := srv.Listener.Addr().String()
+
+	// canceled and timed out requests of the callers do not open the breaker
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if i%2 == 0 {
+			cancel()
+		}
+		assert.Error(t, c.DoJSON(ctx, http.MethodGet, "/", nil, nil))
+		cancel()
+	}
+	assert.Equal(t, BreakerClosed, c.BreakerStates()[host])
+	assert.NoError(t, c.CheckBreakers())
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b := newBreaker(BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Millisecond})
+
+	assert.True(t, b.Allow())
+	b.Report(false)
+	assert.False(t, b.Allow())
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow(), "only a single trial request is allowed")
+	b.Report(true)
+	assert.True(t, b.Allow())
+
+	// released trial lets the next one through
+	b.Report(false)
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, b.Allow())
+	b.Release()
+	assert.True(t, b.Allow())
+
+	disabled := newBreaker(BreakerPolicy{})
+	for i := 0; i < 10; i++ {
+		disabled.Report(false)
+	}
+	assert.True(t, disabled.Allow())
+}
@@ -0,0 +1,427 @@
+// Package httpclient is a base HTTP client for the Bubulearn services clients
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/metrics"
+	"github.com/bubulearn/bubucore/tracing"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeoutDft is a default request timeout
+const timeoutDft = 10 * time.Second
+
+// nameDft is a default Client name
+const nameDft = "http"
+
+// Clients metrics, see metrics.Default
+var (
+	requestsMetric = metrics.Default.Counter(
+		"bubu_http_client_requests_total",
+		"Outbound HTTP requests sent, status is `error` on transport failures.",
+		"client", "method", "status",
+	)
+	latencyMetric = metrics.Default.Histogram(
+		"bubu_http_client_request_duration_seconds",
+		"Outbound HTTP requests latency, including retries.",
+		nil,
+		"client", "method",
+	)
+	retriesMetric = metrics.Default.Counter(
+		"bubu_http_client_retries_total",
+		"Outbound HTTP requests retries.",
+		"client",
+	)
+)
+
+// Options are the Client options
+type Options struct {
+	// Name identifies the Client in metrics, e. g. users, `http` by default
+	Name string
+
+	// BaseURL is prepended to the requests endpoints, e. g. http://users/api/v1
+	BaseURL string
+
+	// LogTag prefixes the errors messages, e. g. `[bubucore][users]`
+	LogTag string
+
+	// Auth is a requests authentication strategy, optional
+	Auth Auth
+
+	// Timeout is a request timeout, 10s by default
+	Timeout time.Duration
+
+	// Transport is a requests transport, NewTransport() by default
+	Transport http.RoundTripper
+
+	// UserAgent is a User-Agent header value, UserAgent() by default
+	UserAgent string
+
+	// Retry is a requests retry policy, DefaultRetryPolicy() by default
+	Retry *RetryPolicy
+
+	// Breaker is a per-host circuit breaker policy, DefaultBreakerPolicy() by default
+	Breaker *BreakerPolicy
+
+	// DecodeError maps the non-2xx responses to errors, optional, see Client.DecodeError
+	DecodeError func(status int, body []byte) error
+}
+
+// New creates new Client instance
+func New(opt Options) *Client {
+	if opt.Name == "" {
+		opt.Name = nameDft
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = timeoutDft
+	}
+	if opt.Transport == nil {
+		opt.Transport = NewTransport()
+	}
+	if opt.UserAgent == "" {
+		opt.UserAgent = UserAgent()
+	}
+	retry := DefaultRetryPolicy()
+	if opt.Retry != nil {
+		retry = *opt.Retry
+	}
+	breakerPolicy := DefaultBreakerPolicy()
+	if opt.Breaker != nil {
+		breakerPolicy = *opt.Breaker
+	}
+	return &Client{
+		opt: opt,
+		client: &http.Client{
+			Transport: opt.Transport,
+			Timeout:   opt.Timeout,
+		},
+		retry:         retry,
+		breakerPolicy: breakerPolicy,
+		breakers:      make(map[string]*breaker),
+	}
+}
+
+// NewTransport creates http.Transport with the default settings
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 60 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ExpectContinueTimeout: 5 * time.Second,
+	}
+}
+
+// UserAgent returns User-Agent header value built from the bubucore.Opt,
+// e. g. `go-http; service-name; v1`
+func UserAgent() string {
+	parts := []string{"go-http"}
+	if bubucore.Opt != nil {
+		if bubucore.Opt.ServiceName != "" {
+			parts = append(parts, bubucore.Opt.ServiceName)
+		}
+		if bubucore.Opt.APIVersion != "" {
+			parts = append(parts, bubucore.Opt.APIVersion)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Client is a base HTTP client: it authenticates requests,
+// encodes and decodes JSON and maps failures to bubucore.Error
+type Client struct {
+	opt    Options
+	client *http.Client
+
+	mu            sync.RWMutex
+	retry         RetryPolicy
+	breakerPolicy BreakerPolicy
+	breakers      map[string]*breaker
+}
+
+// BaseURL returns the Client's base URL
+func (c *Client) BaseURL() string {
+	return c.opt.BaseURL
+}
+
+// HTTPClient returns the underlying http.Client to tune it
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
+// NewRequest creates request to the endpoint relative to the BaseURL.
+// Absolute endpoint URLs are used as is.
+func (c *Client) NewRequest(ctx context.Context, method string, endpoint string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, c.url(endpoint), body)
+}
+
+// Do sends the request with the authentication and the User-Agent set.
+// The trace context and request ID of the request's context are propagated, see tracing.Inject.
+// Failed requests are retried according to the RetryPolicy.
+// If the host's circuit breaker is open, fails fast with the 503 error.
+// Requests canceled by the caller's context do not count as the host's failures.
+func (c *Client) Do(req *http.Request) (resp *http.Response, err error) {
+	start := time.Now()
+	ctx, span := tracing.StartSpan(req.Context(), "HTTP "+req.Method, tracing.SpanKindClient)
+	defer func() {
+		status := "error"
+		if resp != nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		requestsMetric.Inc(c.opt.Name, req.Method, status)
+		latencyMetric.Observe(time.Since(start).Seconds(), c.opt.Name, req.Method)
+
+		if resp != nil {
+			span.SetAttribute("http.status_code", resp.StatusCode)
+			if resp.StatusCode >= 500 {
+				span.SetStatus(tracing.StatusError, http.StatusText(resp.StatusCode))
+			}
+		}
+		span.SetError(err)
+		span.End()
+	}()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	tracing.Inject(ctx, req.Header)
+
+	if c.opt.Auth != nil {
+		if err := c.opt.Auth.Apply(req); err != nil {
+			return nil, err
+		}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.opt.UserAgent)
+	}
+
+	policy := c.RetryPolicy()
+	attempts := policy.attempts(req)
+	cb := c.breaker(req.URL.Host)
+
+	for attempt := 1; ; attempt++ {
+		if !cb.Allow() {
+			return nil, bubucore.NewError(http.StatusServiceUnavailable, c.opt.LogTag+" circuit breaker is open for "+req.URL.Host)
+		}
+
+		if attempt > 1 {
+			retriesMetric.Inc(c.opt.Name)
+		}
+
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err = c.client.Do(req)
+		if req.Context().Err() != nil {
+			// the caller's cancellation is not the host's failure
+			cb.Release()
+		} else {
+			cb.Report(err == nil && resp.StatusCode < 500)
+		}
+
+		if attempt >= attempts || !policy.retryable(resp, err) || req.Context().Err() != nil {
+			return c.result(resp, err)
+		}
+
+		delay, ok := policy.delay(attempt, resp)
+		if !ok {
+			return c.result(resp, err)
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, bubucore.NewError(http.StatusBadGateway, c.opt.LogTag+" request failed: "+req.Context().Err().Error())
+		case <-timer.C:
+		}
+	}
+}
+
+// result maps the transport error to the bubucore.Error
+func (c *Client) result(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, bubucore.NewError(http.StatusBadGateway, c.opt.LogTag+" request failed: "+err.Error())
+	}
+	return resp, nil
+}
+
+// DoRequest sends the request and decodes JSON response to the respData, if not nil.
+// Non-2xx responses are returned as bubucore.Error, see DecodeError.
+func (c *Client) DoRequest(req *http.Request, respData interface{}) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return bubucore.NewError(http.StatusBadGateway, c.opt.LogTag+" failed to read response: "+err.Error())
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return c.DecodeError(resp.StatusCode, body)
+	}
+
+	if respData == nil || len(body) == 0 {
+		return nil
+	}
+
+	err = jsoniter.Unmarshal(body, respData)
+	if err != nil {
+		return bubucore.NewError(http.StatusBadGateway, c.opt.LogTag+" failed to decode response: "+string(body))
+	}
+
+	return nil
+}
+
+// DoJSON sends the reqData encoded to JSON, if not nil, and decodes JSON response to the respData, see DoRequest
+func (c *Client) DoJSON(ctx context.Context, method string, endpoint string, reqData interface{}, respData interface{}) error {
+	var body io.Reader
+	if reqData != nil {
+		data, err := jsoniter.Marshal(reqData)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := c.NewRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	return c.DoRequest(req, respData)
+}
+
+// DecodeError maps the non-2xx response to the bubucore.Error.
+// If the body is a bubucore.Error JSON, it is returned, with the status code if the code is not set.
+// Options.DecodeError replaces this mapping, if set.
+func (c *Client) DecodeError(status int, body []byte) error {
+	if c.opt.DecodeError != nil {
+		return c.opt.DecodeError(status, body)
+	}
+	respErr := &bubucore.Error{}
+	err := jsoniter.Unmarshal(body, respErr)
+	if err == nil && respErr.Message != "" {
+		if respErr.Code == 0 {
+			respErr.Code = status
+		}
+		return respErr
+	}
+	return bubucore.NewError(status, c.opt.LogTag+" non-OK response: "+string(body))
+}
+
+// Close closes idle connections
+func (c *Client) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// RetryPolicy returns the Client's RetryPolicy
+func (c *Client) RetryPolicy() RetryPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.retry
+}
+
+// SetRetryPolicy sets the Client's RetryPolicy
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retry = policy
+}
+
+// SetBreakerPolicy sets the Client's BreakerPolicy. Resets the circuit breakers state.
+func (c *Client) SetBreakerPolicy(policy BreakerPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breakerPolicy = policy
+	c.breakers = make(map[string]*breaker)
+}
+
+// BreakerStates returns the circuit breakers states by host
+func (c *Client) BreakerStates() map[string]BreakerState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make(map[string]BreakerState, len(c.breakers))
+	for host, cb := range c.breakers {
+		states[host] = cb.State()
+	}
+	return states
+}
+
+// CheckBreakers returns an error if any of the circuit breakers is open, to be used in health checks
+func (c *Client) CheckBreakers() error {
+	hosts := make([]string, 0)
+	for host, state := range c.BreakerStates() {
+		if state == BreakerOpen {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	sort.Strings(hosts)
+	return bubucore.NewError(http.StatusServiceUnavailable, c.opt.LogTag+" circuit breaker is open for "+strings.Join(hosts, ", "))
+}
+
+// breaker returns the host's circuit breaker
+func (c *Client) breaker(host string) *breaker {
+	c.mu.RLock()
+	cb, ok := c.breakers[host]
+	c.mu.RUnlock()
+	if ok {
+		return cb
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cb, ok = c.breakers[host]; !ok {
+		cb = newBreaker(c.breakerPolicy)
+		c.breakers[host] = cb
+	}
+	return cb
+}
+
+// url returns the endpoint's URL
+func (c *Client) url(endpoint string) string {
+	if strings.Contains(endpoint, "://") || c.opt.BaseURL == "" {
+		return endpoint
+	}
+	if endpoint == "" {
+		return c.opt.BaseURL
+	}
+	return strings.TrimRight(c.opt.BaseURL, "/") + "/" + strings.TrimLeft(endpoint, "/")
+}
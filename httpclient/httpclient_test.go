@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"context"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/tracing"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_DoJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, UserAgent(), r.Header.Get("User-Agent"))
+
+		switch r.URL.Path {
+		case "/api/echo":
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			body, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		case "/api/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":403,"message":"access denied"}`))
+		case "/api/plain-error":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`oops`))
+		case "/api/invalid":
+			_, _ = w.Write([]byte(`not a json`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"unknown endpoint"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		BaseURL: srv.URL + "/api/",
+		LogTag:  "[test]",
+		Auth:    BearerAuth("token"),
+	})
+	defer func() {
+		_ = c.Close()
+	}()
+
+	ctx := context.Background()
+
+	type payload struct {
+		Value string `json:"value"`
+	}
+	var resp *payload
+	err := c.DoJSON(ctx, http.MethodPost, "/echo", &payload{Value: "test"}, &resp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test", resp.Value)
+	}
+
+	assert.NoError(t, c.DoJSON(ctx, http.MethodDelete, "no-content", nil, &resp))
+
+	err = c.DoJSON(ctx, http.MethodGet, "forbidden", nil, nil)
+	assert.Equal(t, &bubucore.Error{Code: http.StatusForbidden, Message: "access denied"}, err)
+
+	err = c.DoJSON(ctx, http.MethodGet, "unknown", nil, nil)
+	assert.Equal(t, &bubucore.Error{Code: http.StatusNotFound, Message: "unknown endpoint"}, err)
+
+	err = c.DoJSON(ctx, http.MethodGet, "plain-error", nil, nil)
+	assert.Equal(t, bubucore.NewError(http.StatusInternalServerError, "[test] non-OK response: oops"), err)
+
+	err = c.DoJSON(ctx, http.MethodGet, "invalid", nil, &resp)
+	if assert.IsType(t, &bubucore.Error{}, err) {
+		assert.Equal(t, http.StatusBadGateway, err.(*bubucore.Error).Code)
+	}
+
+	// absolute URLs are used as is
+	err = c.DoJSON(ctx, http.MethodPost, srv.URL+"/api/echo", &payload{Value: "abs"}, &resp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "abs", resp.Value)
+	}
+}
+
+func TestClient_TransportError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := New(Options{BaseURL: srv.URL})
+	err := c.DoJSON(context.Background(), http.MethodGet, "/", nil, nil)
+	if assert.IsType(t, &bubucore.Error{}, err) {
+		assert.Equal(t, http.StatusBadGateway, err.(*bubucore.Error).Code)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.NoError(t, HeaderAuth("X-Api-Key", "key").Apply(req))
+	assert.Equal(t, "key", req.Header.Get("X-Api-Key"))
+
+	assert.NoError(t, BasicAuth("user", "pass").Apply(req))
+	user, pass, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "pass", pass)
+}
+
+func TestUserAgent(t *testing.T) {
+	initial := bubucore.Opt.ServiceName
+	defer func() {
+		bubucore.Opt.ServiceName = initial
+	}()
+
+	bubucore.Opt.ServiceName = "test-service"
+	assert.Equal(t, "go-http; test-service; "+bubucore.Opt.APIVersion, UserAgent())
+}
+
+func TestClient_Tracing(t *testing.T) {
+	var traceparent, requestID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(tracing.HeaderTraceparent)
+		requestID = r.Header.Get(tracing.HeaderRequestID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := New(Options{BaseURL: srv.URL})
+
+	ctx := tracing.ContextWithRequestID(context.Background(), "req-1")
+	ctx, span := tracing.StartSpan(ctx, "handler", tracing.SpanKindServer)
+	defer span.End()
+
+	err := c.DoJSON(ctx, http.MethodGet, "/", nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sc, err := tracing.ParseTraceparent(traceparent)
+	if assert.NoError(t, err) {
+		assert.Equal(t, span.SpanContext().TraceID, sc.TraceID)
+		assert.NotEqual(t, span.SpanContext().SpanID, sc.SpanID, "client span is expected")
+	}
+	assert.Equal(t, "req-1", requestID)
+}
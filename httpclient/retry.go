@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is a requests retry policy.
+// Requests of idempotent methods are retried on transport errors, 5xx and 429 responses
+// with the jittered exponential backoff. The Retry-After response header is respected.
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of attempts, including the first one; 1 disables retries
+	MaxAttempts int
+
+	// BaseDelay is a backoff delay before the first retry, doubled for each next one
+	BaseDelay time.Duration
+
+	// MaxDelay limits the backoff delay. Requests with longer Retry-After are not retried.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the default RetryPolicy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// idempotentMethods are methods safe to retry
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// attempts returns max attempts number for the request.
+// Non-idempotent requests are retried only if they have the Idempotency-Key header.
+func (p RetryPolicy) attempts(req *http.Request) int {
+	if p.MaxAttempts <= 1 {
+		return 1
+	}
+	if !idempotentMethods[req.Method] && req.Header.Get("Idempotency-Key") == "" {
+		return 1
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable checks if the request result may be retried
+func (p RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// delay returns the backoff delay before the next attempt.
+// Returns false if the Retry-After delay exceeds the MaxDelay.
+func (p RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= p.MaxDelay
+		}
+	}
+
+	d := p.BaseDelay << uint(attempt-1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	// full jitter
+	return time.Duration(rand.Int63n(int64(d) + 1)), true
+}
+
+// parseRetryAfter parses the Retry-After header, either seconds or HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
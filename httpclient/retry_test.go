@@ -0,0 +1,120 @@
+package httpclient
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Retry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/throttled":
+			if n < 2 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/slow-down":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/bad-request":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		Name:    "retry-test",
+		BaseURL: srv.URL,
+		Retry:   &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		Breaker: &BreakerPolicy{},
+	})
+
+	tests := []struct {
+		method   string
+		endpoint string
+		body     interface{}
+		calls    int32
+		ok       bool
+	}{
+		{http.MethodGet, "/flaky", nil, 3, true},
+		{http.MethodPut, "/flaky", map[string]int{"a": 1}, 3, true},
+		{http.MethodGet, "/throttled", nil, 2, true},
+		{http.MethodGet, "/slow-down", nil, 1, false},
+		{http.MethodGet, "/bad-request", nil, 1, false},
+		{http.MethodGet, "/failed", nil, 3, false},
+		{http.MethodPost, "/flaky", nil, 1, false},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+		err := c.DoJSON(context.Background(), tt.method, tt.endpoint, tt.body, nil)
+		assert.Equal(t, tt.ok, err == nil, tt.method+" "+tt.endpoint)
+		assert.Equal(t, tt.calls, atomic.LoadInt32(&calls), tt.method+" "+tt.endpoint)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	req, _ := c.NewRequest(context.Background(), http.MethodPost, "/flaky", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "key")
+	err := c.DoRequest(req, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	assert.Equal(t, float64(9), retriesMetric.Value("retry-test"))
+	assert.Equal(t, float64(1), requestsMetric.Value("retry-test", http.MethodGet, "429"))
+	assert.Equal(t, float64(1), requestsMetric.Value("retry-test", http.MethodPost, "503"))
+	assert.Equal(t, float64(1), requestsMetric.Value("retry-test", http.MethodPost, "200"))
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		d, ok := p.delay(attempt, nil)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, time.Second)
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "1")
+	d, ok := p.delay(1, resp)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	_, ok = p.delay(1, resp)
+	assert.False(t, ok)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	for _, v := range []string{"", "-1", "soon"} {
+		_, ok = parseRetryAfter(v)
+		assert.False(t, ok, v)
+	}
+}
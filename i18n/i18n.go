@@ -11,6 +11,9 @@ const (
 	LangEn = Language("en")
 )
 
+// DISourceName is a name of the TextsSource dependency in the DI container
+const DISourceName = "bubu_i18n"
+
 // Source is a current texts source
 var Source = &TextsSource{
 	DefaultLang:  LangEn,
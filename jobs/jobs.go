@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bubulearn/bubucore"
+	log "github.com/sirupsen/logrus"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const logTag = "[bubucore.jobs] "
+
+// Worker restart delays
+const (
+	workerRestartDelayMin = time.Second
+	workerRestartDelayMax = time.Minute
+)
+
+// Fn is a job function. The ctx is cancelled on the Runner's stop.
+type Fn func(ctx context.Context) error
+
+// NewRunner creates new Runner instance
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Runner runs background workers and scheduled jobs
+type Runner struct {
+	mu      sync.Mutex
+	workers []*job
+	jobs    []*job
+	locker  Locker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// job is a registered worker or scheduled job
+type job struct {
+	name     string
+	fn       Fn
+	schedule Schedule
+	running  int32
+}
+
+// SetLocker sets Locker to run each scheduled job on a single replica only
+func (r *Runner) SetLocker(locker Locker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locker = locker
+}
+
+// AddWorker adds a long-running worker.
+// The worker is restarted with a growing delay if it fails or panics,
+// returning nil error finishes the worker.
+func (r *Runner) AddWorker(name string, fn Fn) {
+	r.add(&job{name: name, fn: fn}, true)
+}
+
+// Schedule adds a job to run by the cron expression, see ParseSchedule.
+// The job run is skipped if its previous run is still in progress.
+func (r *Runner) Schedule(name string, expr string, fn Fn) error {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return err
+	}
+	r.add(&job{name: name, fn: fn, schedule: schedule}, false)
+	return nil
+}
+
+// Start starts workers and jobs schedules.
+// Jobs added after the start are started immediately.
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	for _, j := range r.workers {
+		r.startWorker(r.ctx, j)
+	}
+	for _, j := range r.jobs {
+		r.startScheduled(r.ctx, j)
+	}
+}
+
+// Stop cancels jobs contexts and waits for them to finish until the ctx is done
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New(logTag + "jobs did not finish in time: " + ctx.Err().Error())
+	}
+}
+
+// add registers the job and starts it if the Runner is started
+func (r *Runner) add(j *job, worker bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if worker {
+		r.workers = append(r.workers, j)
+	} else {
+		r.jobs = append(r.jobs, j)
+	}
+	if !r.started {
+		return
+	}
+	if worker {
+		r.startWorker(r.ctx, j)
+	} else {
+		r.startScheduled(r.ctx, j)
+	}
+}
+
+// startWorker runs the worker, restarting it on failure. Must be called under the lock.
+func (r *Runner) startWorker(ctx context.Context, j *job) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		delay := workerRestartDelayMin
+		for {
+			err := j.run(ctx)
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+
+			j.logger().Warn(logTag, "worker is restarted in ", delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			delay *= 2
+			if delay > workerRestartDelayMax {
+				delay = workerRestartDelayMax
+			}
+		}
+	}()
+}
+
+// startScheduled runs the job by its schedule. Must be called under the lock.
+func (r *Runner) startScheduled(ctx context.Context, j *job) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			now := time.Now()
+			next := j.schedule.Next(now)
+			if next.IsZero() {
+				j.logger().Warn(logTag, "job schedule never fires")
+				return
+			}
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+				j.logger().Warn(logTag, "job run skipped, previous run is still in progress")
+				continue
+			}
+
+			r.wg.Add(1)
+			go func(at time.Time) {
+				defer r.wg.Done()
+				defer atomic.StoreInt32(&j.running, 0)
+
+				if !r.acquire(ctx, j, at) {
+					return
+				}
+				_ = j.run(ctx)
+			}(next)
+		}
+	}()
+}
+
+// acquire acquires the lock of the job run at the given time if the Locker is set
+func (r *Runner) acquire(ctx context.Context, j *job, at time.Time) bool {
+	r.mu.Lock()
+	locker := r.locker
+	r.mu.Unlock()
+	if locker == nil {
+		return true
+	}
+
+	// the lock lives until the next run, so lagging replicas do not repeat the run
+	ttl := j.schedule.Next(at).Sub(at)
+	key := j.name + ":" + fmt.Sprint(at.UnixMilli())
+
+	ok, err := locker.TryLock(ctx, key, ttl)
+	if err != nil {
+		j.logger().Error(logTag, "failed to acquire job lock: ", err)
+		return false
+	}
+	if !ok {
+		j.logger().Debug(logTag, "job run skipped, it is run by another replica")
+	}
+	return ok
+}
+
+// run calls the job function, recovering and logging panics and errors
+func (j *job) run(ctx context.Context) (err error) {
+	logger := j.logger()
+	start := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			logger.WithField("stack", string(debug.Stack())).Error(logTag, "job panicked: ", r)
+			return
+		}
+		logger = logger.WithField("duration", time.Since(start).String())
+		if err != nil && ctx.Err() == nil {
+			logger.Error(logTag, "job failed: ", err)
+		} else {
+			logger.Debug(logTag, "job finished")
+		}
+	}()
+
+	logger.Debug(logTag, "job started")
+
+	return j.fn(ctx)
+}
+
+// logger returns the job's log entry
+func (j *job) logger() *log.Entry {
+	return log.WithFields(log.Fields{
+		bubucore.LogFieldType: bubucore.LogTypeJob,
+		bubucore.LogFieldJob:  j.name,
+	})
+}
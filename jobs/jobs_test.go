@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type lockerMock struct {
+	mu   sync.Mutex
+	keys []string
+	ok   bool
+}
+
+func (l *lockerMock) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+	return l.ok, nil
+}
+
+func (l *lockerMock) set(ok bool) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ok = ok
+	return append([]string{}, l.keys...)
+}
+
+func TestRunner_AddWorker(t *testing.T) {
+	r := NewRunner()
+
+	started := make(chan struct{})
+	var stopped int32
+	r.AddWorker("worker", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		atomic.StoreInt32(&stopped, 1)
+		return ctx.Err()
+	})
+
+	r.Start(context.Background())
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("worker is not started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, r.Stop(ctx))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped))
+}
+
+func TestRunner_StopTimeout(t *testing.T) {
+	r := NewRunner()
+	r.Start(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	r.AddWorker("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, r.Stop(ctx))
+}
+
+// memoryLocker is an in-memory Locker shared by the runners
+type memoryLocker struct {
+	mu       sync.Mutex
+	locks    map[string]time.Time
+	attempts map[string]int
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{
+		locks:    make(map[string]time.Time),
+		attempts: make(map[string]int),
+	}
+}
+
+func (l *memoryLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts[key]++
+	if exp, ok := l.locks[key]; ok && time.Now().Before(exp) {
+		return false, nil
+	}
+	l.locks[key] = time.Now().Add(ttl)
+	return true, nil
+}
+
+func TestRunner_Schedule(t *testing.T) {
+	r := NewRunner()
+
+	assert.Error(t, r.Schedule("invalid", "* * *", func(ctx context.Context) error { return nil }))
+
+	var runs int32
+	r.add(&job{
+		name:     "slow",
+		schedule: &everySchedule{interval: 50 * time.Millisecond},
+		fn: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			<-ctx.Done()
+			return nil
+		},
+	}, false)
+
+	locker := &lockerMock{ok: false}
+	r.SetLocker(locker)
+
+	r.Start(context.Background())
+	time.Sleep(125 * time.Millisecond)
+
+	// runs are skipped by the locker
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+	keys := locker.set(true)
+	if assert.NotEmpty(t, keys) {
+		assert.Contains(t, keys[0], "slow:")
+	}
+
+	time.Sleep(125 * time.Millisecond)
+
+	// the slow run prevents the next ones
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, r.Stop(ctx))
+}
+
+func TestRunner_ScheduleReplicas(t *testing.T) {
+	locker := newMemoryLocker()
+
+	var runs int32
+	runners := make([]*Runner, 2)
+	for i := range runners {
+		runners[i] = NewRunner()
+		runners[i].SetLocker(locker)
+		runners[i].add(&job{
+			name:     "replicated",
+			schedule: &everySchedule{interval: 50 * time.Millisecond},
+			fn: func(ctx context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		}, false)
+	}
+
+	// the replicas are started at different times
+	runners[0].Start(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	runners[1].Start(context.Background())
+	time.Sleep(300 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, r := range runners {
+		assert.NoError(t, r.Stop(ctx))
+	}
+
+	// both replicas compete for the same runs, each run is done once
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+	shared := 0
+	for _, n := range locker.attempts {
+		if n == 2 {
+			shared++
+		}
+	}
+	assert.GreaterOrEqual(t, shared, 3)
+	assert.EqualValues(t, len(locker.locks), atomic.LoadInt32(&runs))
+}
+
+func TestJob_run(t *testing.T) {
+	j := &job{name: "panic", fn: func(ctx context.Context) error {
+		panic("oops")
+	}}
+	err := j.run(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "oops")
+	}
+
+	j = &job{name: "fail", fn: func(ctx context.Context) error {
+		return errors.New("failed")
+	}}
+	assert.EqualError(t, j.run(context.Background()), "failed")
+}
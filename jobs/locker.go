@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"github.com/bubulearn/bubucore"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// Locker is a distributed lock to run the scheduled jobs on a single replica
+type Locker interface {
+	// TryLock acquires the key lock for the ttl. Returns false if the key is locked already.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// NewRedisLocker creates new RedisLocker instance
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		prefix: "bubu_job_lock:" + bubucore.Opt.ServiceName + ":",
+	}
+}
+
+// RedisLocker is a Redis based Locker
+type RedisLocker struct {
+	client *redis.Client
+	prefix string
+}
+
+// TryLock acquires the key lock with the SET NX command, the lock value is a hostname
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return l.client.SetNX(ctx, l.prefix+key, bubucore.Opt.GetHostname(), ttl).Result()
+}
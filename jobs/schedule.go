@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes job run times
+type Schedule interface {
+	// Next returns the next run time after the t
+	Next(t time.Time) time.Time
+}
+
+// cronDescriptors are predefined cron expressions
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is a cron expression field bounds
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+// cronFields are the cron expression fields in order
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses the standard 5-fields cron expression: minute, hour, day of month, month and day of week.
+// Fields support `*`, values, ranges `a-b`, steps `*/n` or `a-b/n` and lists `a,b`.
+// Descriptors `@yearly`, `@monthly`, `@weekly`, `@daily`, `@hourly` and `@every <duration>` are supported too.
+// The `@every` runs are aligned to the interval multiples, e. g. `@every 15m` fires at :00, :15, :30 and :45.
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, errors.New(logTag + "invalid schedule `" + expr + "`: " + err.Error())
+		}
+		if d < time.Second {
+			return nil, errors.New(logTag + "invalid schedule `" + expr + "`: interval must be at least 1s")
+		}
+		return &everySchedule{interval: d}, nil
+	}
+
+	if descr, ok := cronDescriptors[expr]; ok {
+		expr = descr
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, errors.New(logTag + "invalid schedule `" + expr + "`: 5 fields expected")
+	}
+
+	bits := make([]uint64, len(cronFields))
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, errors.New(logTag + "invalid schedule `" + expr + "`: " + err.Error())
+		}
+		bits[i] = b
+	}
+
+	s := &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}
+
+	// 7 is a Sunday alias
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// parseCronField parses the cron expression field to the bit set of allowed values
+func parseCronField(expr string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, errors.New("invalid " + f.name + " step `" + stepStr + "`")
+			}
+		}
+
+		from, to := f.min, f.max
+		if rng != "*" {
+			fromStr, toStr, isRange := strings.Cut(rng, "-")
+			var err error
+			from, err = strconv.Atoi(fromStr)
+			if err != nil {
+				return 0, errors.New("invalid " + f.name + " value `" + fromStr + "`")
+			}
+			to = from
+			if isRange {
+				to, err = strconv.Atoi(toStr)
+				if err != nil {
+					return 0, errors.New("invalid " + f.name + " value `" + toStr + "`")
+				}
+			} else if hasStep {
+				to = f.max
+			}
+		}
+
+		if from < f.min || to > f.max || from > to {
+			return 0, errors.New(f.name + " `" + item + "` is out of range " + strconv.Itoa(f.min) + "-" + strconv.Itoa(f.max))
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronSchedule is a cron expression schedule
+type cronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	domAny bool
+	dowAny bool
+}
+
+// cronSearchLimit limits the next run time search for the impossible dates like 30 Feb
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// Next returns the next run time after the t in the t's location.
+// Returns zero time if the schedule never fires.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchDay checks the day of month and the day of week.
+// Like in cron, if both are restricted, either of them should match.
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// everySchedule is a fixed interval schedule
+type everySchedule struct {
+	interval time.Duration
+}
+
+// Next returns the next multiple of the interval after the t, counted since the zero time,
+// so the replicas started at different times fire at the same times
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.interval).Add(s.interval)
+}
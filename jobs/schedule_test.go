@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	at := time.Date(2022, time.February, 25, 17, 50, 30, 0, time.UTC) // Friday
+
+	valid := map[string]time.Time{
+		"* * * * *":           time.Date(2022, time.February, 25, 17, 51, 0, 0, time.UTC),
+		" 0  0  *  *  * ":     time.Date(2022, time.February, 26, 0, 0, 0, 0, time.UTC),
+		"*/15 9-17 * * 1-5":   time.Date(2022, time.February, 28, 9, 0, 0, 0, time.UTC),
+		"0,55 17 * * *":       time.Date(2022, time.February, 25, 17, 55, 0, 0, time.UTC),
+		"10/20 * * * *":       time.Date(2022, time.February, 25, 18, 10, 0, 0, time.UTC),
+		"0-4 18 25 2 *":       time.Date(2022, time.February, 25, 18, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":          time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 31 2 *":          {},
+		"0 12 1 * 0":          time.Date(2022, time.February, 27, 12, 0, 0, 0, time.UTC),
+		"0 0 1-7 * 1":         time.Date(2022, time.February, 28, 0, 0, 0, 0, time.UTC),
+		"30 3 * * 7":          time.Date(2022, time.February, 27, 3, 30, 0, 0, time.UTC),
+		"0 0 * * 5":           time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 0-6/2":       time.Date(2022, time.February, 26, 0, 0, 0, 0, time.UTC),
+		"0 8 * 1-12/6 *":      time.Date(2022, time.July, 1, 8, 0, 0, 0, time.UTC),
+		"*/30 */6 */10 */3 *": time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC),
+		"@daily":              time.Date(2022, time.February, 26, 0, 0, 0, 0, time.UTC),
+		"@hourly":             time.Date(2022, time.February, 25, 18, 0, 0, 0, time.UTC),
+		"@monthly":            time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC),
+		"@yearly":             time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"@every 90s":          time.Date(2022, time.February, 25, 17, 51, 0, 0, time.UTC),
+		"@every 15m":          time.Date(2022, time.February, 25, 18, 0, 0, 0, time.UTC),
+	}
+	for expr, expected := range valid {
+		s, err := ParseSchedule(expr)
+		if assert.NoError(t, err, expr) {
+			assert.Equal(t, expected, s.Next(at), expr)
+		}
+	}
+
+	invalid := []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"5-1 * * * *", "*/0 * * * *", "0 8 * jan *", "5 4 * * sun", "@every 1ms", "@every x",
+	}
+	for _, expr := range invalid {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
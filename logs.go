@@ -1,6 +1,7 @@
 package bubucore
 
 import (
+	"github.com/bubulearn/bubucore/tracing"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"io"
@@ -22,6 +23,10 @@ const (
 	LogFieldMethod            = "method"
 	LogFieldClientAppVersion  = "client_app_ver"
 	LogFieldClientAppPlatform = "client_app_platform"
+	LogFieldJob               = "job"
+	LogFieldTraceID           = "trace_id"
+	LogFieldSpanID            = "span_id"
+	LogFieldRequestID         = "request_id"
 )
 
 // Log types
@@ -30,6 +35,7 @@ const (
 	LogTypeHTTPIO  = "http_io"
 	LogTypeApp     = "app"
 	LogTypeSocket  = "socket"
+	LogTypeJob     = "job"
 )
 
 // region WRITERS
@@ -48,6 +54,7 @@ func InitLogs() {
 
 	log.SetOutput(initLogWriter(Opt.LogsPath + "/" + Opt.LogFileApp))
 	log.AddHook(&LogDftFieldsHook{})
+	log.AddHook(&LogTraceHook{})
 
 	gin.DefaultWriter = initLogWriter(Opt.LogsPath + "/" + Opt.LogFileGin)
 }
@@ -86,4 +93,27 @@ func (h *LogDftFieldsHook) Fire(e *log.Entry) error {
 	return nil
 }
 
+// LogTraceHook is a log's hook to add tracing fields to messages logged with a context, see log.WithContext
+type LogTraceHook struct{}
+
+// Levels to apply hook to
+func (h *LogTraceHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire the hook
+func (h *LogTraceHook) Fire(e *log.Entry) error {
+	if e.Context == nil {
+		return nil
+	}
+	if sc, ok := tracing.SpanContextFromContext(e.Context); ok {
+		e.Data[LogFieldTraceID] = sc.TraceID.String()
+		e.Data[LogFieldSpanID] = sc.SpanID.String()
+	}
+	if id := tracing.RequestIDFromContext(e.Context); id != "" {
+		e.Data[LogFieldRequestID] = id
+	}
+	return nil
+}
+
 // endregion GIN BODY
@@ -1,7 +1,9 @@
 package bubucore_test
 
 import (
+	"context"
 	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/tracing"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -13,3 +15,19 @@ func TestInitLogs(t *testing.T) {
 	assert.Equal(t, log.WarnLevel, log.GetLevel())
 	log.SetLevel(log.DebugLevel)
 }
+
+func TestLogTraceHook_Fire(t *testing.T) {
+	ctx := tracing.ContextWithRequestID(context.Background(), "req-1")
+	ctx, span := tracing.StartSpan(ctx, "op", tracing.SpanKindInternal)
+	defer span.End()
+
+	e := log.WithContext(ctx)
+	assert.NoError(t, (&bubucore.LogTraceHook{}).Fire(e))
+	assert.Equal(t, span.SpanContext().TraceID.String(), e.Data[bubucore.LogFieldTraceID])
+	assert.Equal(t, span.SpanContext().SpanID.String(), e.Data[bubucore.LogFieldSpanID])
+	assert.Equal(t, "req-1", e.Data[bubucore.LogFieldRequestID])
+
+	e = log.WithField("key", "value")
+	assert.NoError(t, (&bubucore.LogTraceHook{}).Fire(e))
+	assert.NotContains(t, e.Data, bubucore.LogFieldTraceID)
+}
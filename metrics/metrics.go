@@ -0,0 +1,265 @@
+// Package metrics is a minimal metrics registry exposed in the Prometheus text format
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets in seconds, suited for network calls latency
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the default Registry the bubucore subsystems are instrumented with
+var Default = NewRegistry()
+
+// Metric types
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// labelsSep separates label values in the series keys
+const labelsSep = "\xff"
+
+// metric is a registered metric family
+type metric interface {
+	describe() *desc
+}
+
+// desc describes the metric family
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// describe returns the metric family description
+func (d *desc) describe() *desc {
+	return d
+}
+
+// key builds the series key from the label values, panics on the label values count mismatch
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic("[bubucore.metrics] " + d.name + ": expected " + strings.Join(d.labels, ", ") + " label values")
+	}
+	return strings.Join(values, labelsSep)
+}
+
+// atomicFloat is a float64 updated atomically
+type atomicFloat struct {
+	bits uint64
+}
+
+// Add adds the v to the value
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		upd := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, upd) {
+			return
+		}
+	}
+}
+
+// Load returns the value
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// CounterVec is a counters family partitioned by labels
+type CounterVec struct {
+	*desc
+	mu     sync.RWMutex
+	series map[string]*atomicFloat
+}
+
+// Inc increments the counter of the label values by 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the non-negative v to the counter of the label values
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.get(labelValues).Add(v)
+}
+
+// Value returns the counter value of the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if s, ok := c.series[c.key(labelValues)]; ok {
+		return s.Load()
+	}
+	return 0
+}
+
+// get returns the series of the label values, creating it if needed
+func (c *CounterVec) get(labelValues []string) *atomicFloat {
+	key := c.key(labelValues)
+
+	c.mu.RLock()
+	s, ok := c.series[key]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok = c.series[key]; !ok {
+		s = &atomicFloat{}
+		c.series[key] = s
+	}
+	return s
+}
+
+// HistogramVec is a histograms family partitioned by labels
+type HistogramVec struct {
+	*desc
+	buckets []float64
+	mu      sync.RWMutex
+	series  map[string]*histogram
+}
+
+// histogram is a single histogram series
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    atomicFloat
+}
+
+// Observe adds the v observation to the histogram of the label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		atomic.AddUint64(&s.counts[i], 1)
+	}
+	atomic.AddUint64(&s.count, 1)
+	s.sum.Add(v)
+}
+
+// Count returns the observations count of the label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if s, ok := h.series[h.key(labelValues)]; ok {
+		return atomic.LoadUint64(&s.count)
+	}
+	return 0
+}
+
+// get returns the series of the label values, creating it if needed
+func (h *HistogramVec) get(labelValues []string) *histogram {
+	key := h.key(labelValues)
+
+	h.mu.RLock()
+	s, ok := h.series[key]
+	h.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok = h.series[key]; !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	return s
+}
+
+// GaugeFunc is a gauge which value is read on the collection
+type GaugeFunc struct {
+	*desc
+	fn func() float64
+}
+
+// NewRegistry creates new Registry instance
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// Registry is a metrics families registry. Safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+}
+
+// Counter registers the counters family, or returns the registered one with the same name.
+// Panics if the name is registered with another type.
+func (r *Registry) Counter(name string, help string, labels ...string) *CounterVec {
+	m := r.register(name, func() metric {
+		return &CounterVec{
+			desc:   &desc{name: name, help: help, typ: TypeCounter, labels: labels},
+			series: make(map[string]*atomicFloat),
+		}
+	})
+	c, ok := m.(*CounterVec)
+	if !ok {
+		panic("[bubucore.metrics] " + name + " is already registered as " + m.describe().typ)
+	}
+	return c
+}
+
+// Histogram registers the histograms family, or returns the registered one with the same name.
+// The buckets are upper bounds in increasing order, DefBuckets if empty.
+// Panics if the name is registered with another type.
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	m := r.register(name, func() metric {
+		b := append([]float64{}, buckets...)
+		sort.Float64s(b)
+		return &HistogramVec{
+			desc:    &desc{name: name, help: help, typ: TypeHistogram, labels: labels},
+			buckets: b,
+			series:  make(map[string]*histogram),
+		}
+	})
+	h, ok := m.(*HistogramVec)
+	if !ok {
+		panic("[bubucore.metrics] " + name + " is already registered as " + m.describe().typ)
+	}
+	return h
+}
+
+// GaugeFunc registers the gauge read with the fn, replacing the registered one with the same name
+func (r *Registry) GaugeFunc(name string, help string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = &GaugeFunc{
+		desc: &desc{name: name, help: help, typ: TypeGauge},
+		fn:   fn,
+	}
+}
+
+// register returns the metric by name, creating it with the create func if not registered
+func (r *Registry) register(name string, create func() metric) metric {
+	r.mu.RLock()
+	m, ok := r.metrics[name]
+	r.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok = r.metrics[name]; !ok {
+		m = create()
+		r.metrics[name] = m
+	}
+	return m
+}
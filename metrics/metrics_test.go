@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.Counter("requests_total", "Requests handled.", "method", "path")
+	requests.Inc("GET", "/items")
+	requests.Add(2, "GET", "/items")
+	requests.Inc("POST", `/say "hi"`)
+	requests.Add(-1, "POST", `/say "hi"`)
+
+	latency := r.Histogram("latency_seconds", "Requests latency.", []float64{1, 0.1}, "method")
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.5, "GET")
+	latency.Observe(5, "GET")
+
+	r.GaugeFunc("goroutines", "Line one\nline two.", func() float64 { return 7 })
+
+	expected := `# HELP goroutines Line one\nline two.
+# TYPE goroutines gauge
+goroutines 7
+# HELP latency_seconds Requests latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="1"} 2
+latency_seconds_bucket{method="GET",le="+Inf"} 3
+latency_seconds_sum{method="GET"} 5.55
+latency_seconds_count{method="GET"} 3
+# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{method="GET",path="/items"} 3
+requests_total{method="POST",path="/say \"hi\""} 1
+`
+
+	out := &strings.Builder{}
+	assert.NoError(t, r.WriteText(out))
+	assert.Equal(t, expected, out.String())
+
+	assert.Equal(t, float64(3), requests.Value("GET", "/items"))
+	assert.Equal(t, float64(0), requests.Value("GET", "/unknown"))
+	assert.Equal(t, uint64(3), latency.Count("GET"))
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("total", "")
+	assert.Same(t, c, r.Counter("total", ""))
+
+	assert.Panics(t, func() {
+		r.Histogram("total", "", nil)
+	})
+	assert.Panics(t, func() {
+		c.Inc("unexpected")
+	})
+}
+
+func TestCounterVec_Concurrent(t *testing.T) {
+	c := NewRegistry().Counter("total", "", "worker")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc("w")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(1000), c.Value("w"))
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("total", "Total.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "total 1\n")
+}
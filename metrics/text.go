@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ContentType is the Prometheus text exposition format content type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelsEscaper escapes label values
+var labelsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpEscaper escapes help texts
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// Handler returns http.Handler serving the Default registry metrics
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// Handler returns http.Handler serving the Registry metrics in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.WriteText(w)
+	})
+}
+
+// WriteText writes the Registry metrics in the Prometheus text format, sorted by name and labels
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	metrics := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].describe().name < metrics[j].describe().name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		d := m.describe()
+		if d.help != "" {
+			_, _ = bw.WriteString("# HELP " + d.name + " " + helpEscaper.Replace(d.help) + "\n")
+		}
+		_, _ = bw.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+
+		switch v := m.(type) {
+		case *CounterVec:
+			v.write(bw)
+		case *HistogramVec:
+			v.write(bw)
+		case *GaugeFunc:
+			writeSample(bw, d.name, "", v.fn())
+		}
+	}
+	return bw.Flush()
+}
+
+// write writes the counters samples
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.RLock()
+	keys := sortedKeys(c.series)
+	values := make([]float64, len(keys))
+	for i, k := range keys {
+		values[i] = c.series[k].Load()
+	}
+	c.mu.RUnlock()
+
+	for i, k := range keys {
+		writeSample(w, c.name, formatLabels(c.labels, k, ""), values[i])
+	}
+}
+
+// write writes the histograms samples
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.RLock()
+	keys := sortedKeys(h.series)
+	series := make([]*histogram, len(keys))
+	for i, k := range keys {
+		series[i] = h.series[k]
+	}
+	h.mu.RUnlock()
+
+	for i, k := range keys {
+		s := series[i]
+		var cumulative uint64
+		for j, bound := range h.buckets {
+			cumulative += atomic.LoadUint64(&s.counts[j])
+			le := `le="` + formatFloat(bound) + `"`
+			writeSample(w, h.name+"_bucket", formatLabels(h.labels, k, le), float64(cumulative))
+		}
+		count := atomic.LoadUint64(&s.count)
+		writeSample(w, h.name+"_bucket", formatLabels(h.labels, k, `le="+Inf"`), float64(count))
+		writeSample(w, h.name+"_sum", formatLabels(h.labels, k, ""), s.sum.Load())
+		writeSample(w, h.name+"_count", formatLabels(h.labels, k, ""), float64(count))
+	}
+}
+
+// writeSample writes a single sample line
+func writeSample(w *bufio.Writer, name string, labels string, v float64) {
+	_, _ = w.WriteString(name + labels + " " + formatFloat(v) + "\n")
+}
+
+// formatLabels formats the series labels, with the extra label appended if not empty
+func formatLabels(names []string, key string, extra string) string {
+	pairs := make([]string, 0, len(names)+1)
+	if len(names) > 0 {
+		values := strings.Split(key, labelsSep)
+		for i, name := range names {
+			pairs = append(pairs, name+`="`+labelsEscaper.Replace(values[i])+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats the value as Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// sortedKeys returns the map keys sorted
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
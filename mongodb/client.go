@@ -20,7 +20,13 @@ type ClientOptions struct {
 // CreateMongoClient creates new mongo client and database instances.
 // Deprecated, use NewMongoDB instead.
 func CreateMongoClient(opt *ClientOptions) (*mongo.Client, *mongo.Database, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	return CreateMongoClientContext(context.Background(), opt)
+}
+
+// CreateMongoClientContext creates new mongo client and database instances within the ctx.
+// Deprecated, use NewMongoDBContext instead.
+func CreateMongoClientContext(ctx context.Context, opt *ClientOptions) (*mongo.Client, *mongo.Database, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
 	var err error
@@ -3,6 +3,7 @@ package mongodb
 import (
 	"context"
 	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/metrics"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -35,6 +36,40 @@ type DAO interface {
 	Err(err error) error
 }
 
+// DAOContext is an interface for DAOs propagating the caller's context, e. g. the request's one.
+// DAOMg implements it, type-assert the DAO to use it.
+type DAOContext interface {
+	DAO
+
+	FetchByIDContext(ctx context.Context, id string, target interface{}, opts ...*options.FindOneOptions) error
+	FetchByIDsContext(ctx context.Context, ids []string, target interface{}, opts ...*options.FindOptions) error
+	FetchByExIDsContext(ctx context.Context, ids []string, target interface{}, opts ...*options.FindOptions) error
+
+	FetchOneContext(ctx context.Context, target interface{}, filter interface{}, opts ...*options.FindOneOptions) error
+	FetchAllContext(ctx context.Context, target interface{}, opts ...*options.FindOptions) error
+	FetchAllFContext(ctx context.Context, target interface{}, filter interface{}, opts ...*options.FindOptions) error
+
+	InsertOneContext(ctx context.Context, data interface{}, opts ...*options.InsertOneOptions) (id string, err error)
+	InsertManyContext(ctx context.Context, rows []interface{}, opts ...*options.InsertManyOptions) (insertedIDs []string, err error)
+
+	UpdateByIDContext(ctx context.Context, id string, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateOneContext(ctx context.Context, filter interface{}, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+
+	DeleteByIDContext(ctx context.Context, id string, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	DeleteOneContext(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	DeleteManyContext(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+
+	CtxFrom(parent context.Context, seconds uint) (context.Context, context.CancelFunc)
+}
+
+// daoLatencyMetric is a DAO operations latency histogram, see metrics.Default
+var daoLatencyMetric = metrics.Default.Histogram(
+	"bubu_dao_operation_duration_seconds",
+	"MongoDB DAO operations latency.",
+	nil,
+	"collection", "operation",
+)
+
 // NewDAOMg creates new DAOMg instance with the specified collection
 func NewDAOMg(collection *mongo.Collection) *DAOMg {
 	return &DAOMg{
@@ -47,9 +82,15 @@ type DAOMg struct {
 	c *mongo.Collection
 }
 
-// FetchByID fetches row by ID to the target
+// FetchByID fetches row by ID to the target, see FetchByIDContext
 func (d *DAOMg) FetchByID(id string, target interface{}, opts ...*options.FindOneOptions) error {
-	ctx, cancel := d.Ctx(1)
+	return d.FetchByIDContext(context.Background(), id, target, opts...)
+}
+
+// FetchByIDContext fetches row by ID to the target
+func (d *DAOMg) FetchByIDContext(ctx context.Context, id string, target interface{}, opts ...*options.FindOneOptions) error {
+	defer d.observe("find_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 1)
 	defer cancel()
 
 	filter := bson.M{"_id": id}
@@ -62,21 +103,37 @@ func (d *DAOMg) FetchByID(id string, target interface{}, opts ...*options.FindOn
 	return nil
 }
 
-// FetchByIDs fetches rows by IDs list
+// FetchByIDs fetches rows by IDs list, see FetchByIDsContext
 func (d *DAOMg) FetchByIDs(ids []string, target interface{}, opts ...*options.FindOptions) error {
+	return d.FetchByIDsContext(context.Background(), ids, target, opts...)
+}
+
+// FetchByIDsContext fetches rows by IDs list
+func (d *DAOMg) FetchByIDsContext(ctx context.Context, ids []string, target interface{}, opts ...*options.FindOptions) error {
 	filter := bson.M{"_id": bson.M{"$in": ids}}
-	return d.FetchAllF(target, filter, opts...)
+	return d.FetchAllFContext(ctx, target, filter, opts...)
 }
 
-// FetchByExIDs fetches rows by exclude IDs list
+// FetchByExIDs fetches rows by exclude IDs list, see FetchByExIDsContext
 func (d *DAOMg) FetchByExIDs(ids []string, target interface{}, opts ...*options.FindOptions) error {
+	return d.FetchByExIDsContext(context.Background(), ids, target, opts...)
+}
+
+// FetchByExIDsContext fetches rows by exclude IDs list
+func (d *DAOMg) FetchByExIDsContext(ctx context.Context, ids []string, target interface{}, opts ...*options.FindOptions) error {
 	filter := bson.M{"_id": bson.M{"$nin": ids}}
-	return d.FetchAllF(target, filter, opts...)
+	return d.FetchAllFContext(ctx, target, filter, opts...)
 }
 
-// FetchOne fetches one row by the filter
+// FetchOne fetches one row by the filter, see FetchOneContext
 func (d *DAOMg) FetchOne(target interface{}, filter interface{}, opts ...*options.FindOneOptions) error {
-	ctx, cancel := d.Ctx(1)
+	return d.FetchOneContext(context.Background(), target, filter, opts...)
+}
+
+// FetchOneContext fetches one row by the filter
+func (d *DAOMg) FetchOneContext(ctx context.Context, target interface{}, filter interface{}, opts ...*options.FindOneOptions) error {
+	defer d.observe("find_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 1)
 	defer cancel()
 
 	err := d.C().FindOne(ctx, filter, opts...).Decode(target)
@@ -88,14 +145,25 @@ func (d *DAOMg) FetchOne(target interface{}, filter interface{}, opts ...*option
 	return nil
 }
 
-// FetchAll fetches all rows from cursor to the target
+// FetchAll fetches all rows from cursor to the target, see FetchAllContext
 func (d *DAOMg) FetchAll(target interface{}, opts ...*options.FindOptions) error {
-	return d.FetchAllF(target, bson.M{}, opts...)
+	return d.FetchAllContext(context.Background(), target, opts...)
+}
+
+// FetchAllContext fetches all rows from cursor to the target
+func (d *DAOMg) FetchAllContext(ctx context.Context, target interface{}, opts ...*options.FindOptions) error {
+	return d.FetchAllFContext(ctx, target, bson.M{}, opts...)
 }
 
-// FetchAllF fetches all rows from cursor with filter to the target
+// FetchAllF fetches all rows from cursor with filter to the target, see FetchAllFContext
 func (d *DAOMg) FetchAllF(target interface{}, filter interface{}, opts ...*options.FindOptions) error {
-	ctx, cancel := d.Ctx(10)
+	return d.FetchAllFContext(context.Background(), target, filter, opts...)
+}
+
+// FetchAllFContext fetches all rows from cursor with filter to the target
+func (d *DAOMg) FetchAllFContext(ctx context.Context, target interface{}, filter interface{}, opts ...*options.FindOptions) error {
+	defer d.observe("find", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 10)
 	defer cancel()
 
 	cur, err := d.C().Find(ctx, filter, opts...)
@@ -116,9 +184,15 @@ func (d *DAOMg) FetchAllF(target interface{}, filter interface{}, opts ...*optio
 	return nil
 }
 
-// InsertOne insets row to the collection
+// InsertOne insets row to the collection, see InsertOneContext
 func (d *DAOMg) InsertOne(data interface{}, opts ...*options.InsertOneOptions) (id string, err error) {
-	ctx, cancel := d.Ctx(3)
+	return d.InsertOneContext(context.Background(), data, opts...)
+}
+
+// InsertOneContext insets row to the collection
+func (d *DAOMg) InsertOneContext(ctx context.Context, data interface{}, opts ...*options.InsertOneOptions) (id string, err error) {
+	defer d.observe("insert_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 3)
 	defer cancel()
 
 	doc, err := bson.Marshal(data)
@@ -144,9 +218,15 @@ func (d *DAOMg) InsertOne(data interface{}, opts ...*options.InsertOneOptions) (
 	return "", nil
 }
 
-// InsertMany inserts multiple documents to the collection
+// InsertMany inserts multiple documents to the collection, see InsertManyContext
 func (d *DAOMg) InsertMany(rows []interface{}, opts ...*options.InsertManyOptions) (insertedIDs []string, err error) {
-	ctx, cancel := d.Ctx(30)
+	return d.InsertManyContext(context.Background(), rows, opts...)
+}
+
+// InsertManyContext inserts multiple documents to the collection
+func (d *DAOMg) InsertManyContext(ctx context.Context, rows []interface{}, opts ...*options.InsertManyOptions) (insertedIDs []string, err error) {
+	defer d.observe("insert_many", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 30)
 	defer cancel()
 
 	docs := make([]interface{}, len(rows))
@@ -187,9 +267,15 @@ func (d *DAOMg) InsertMany(rows []interface{}, opts ...*options.InsertManyOption
 	return insertedIDs, nil
 }
 
-// UpdateByID updates one row by ID
+// UpdateByID updates one row by ID, see UpdateByIDContext
 func (d *DAOMg) UpdateByID(id string, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
-	ctx, cancel := d.Ctx(3)
+	return d.UpdateByIDContext(context.Background(), id, data, opts...)
+}
+
+// UpdateByIDContext updates one row by ID
+func (d *DAOMg) UpdateByIDContext(ctx context.Context, id string, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	defer d.observe("update_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 3)
 	defer cancel()
 
 	upd := map[string]interface{}{
@@ -203,9 +289,15 @@ func (d *DAOMg) UpdateByID(id string, data interface{}, opts ...*options.UpdateO
 	return d.C().UpdateByID(ctx, id, doc, opts...)
 }
 
-// UpdateOne updates one row
+// UpdateOne updates one row, see UpdateOneContext
 func (d *DAOMg) UpdateOne(filter interface{}, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
-	ctx, cancel := d.Ctx(3)
+	return d.UpdateOneContext(context.Background(), filter, data, opts...)
+}
+
+// UpdateOneContext updates one row
+func (d *DAOMg) UpdateOneContext(ctx context.Context, filter interface{}, data interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	defer d.observe("update_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 3)
 	defer cancel()
 
 	upd := map[string]interface{}{
@@ -219,22 +311,39 @@ func (d *DAOMg) UpdateOne(filter interface{}, data interface{}, opts ...*options
 	return d.C().UpdateOne(ctx, filter, doc, opts...)
 }
 
-// DeleteByID deletes one row by ID
+// DeleteByID deletes one row by ID, see DeleteByIDContext
 func (d *DAOMg) DeleteByID(id string, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return d.DeleteByIDContext(context.Background(), id, opts...)
+}
+
+// DeleteByIDContext deletes one row by ID
+func (d *DAOMg) DeleteByIDContext(ctx context.Context, id string, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
 	filter := bson.M{"_id": id}
-	return d.DeleteOne(filter, opts...)
+	return d.DeleteOneContext(ctx, filter, opts...)
 }
 
-// DeleteOne deletes one row
+// DeleteOne deletes one row, see DeleteOneContext
 func (d *DAOMg) DeleteOne(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-	ctx, cancel := d.Ctx(3)
+	return d.DeleteOneContext(context.Background(), filter, opts...)
+}
+
+// DeleteOneContext deletes one row
+func (d *DAOMg) DeleteOneContext(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	defer d.observe("delete_one", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 3)
 	defer cancel()
 	return d.C().DeleteOne(ctx, filter, opts...)
 }
 
-// DeleteMany deletes filtered rows
+// DeleteMany deletes filtered rows, see DeleteManyContext
 func (d *DAOMg) DeleteMany(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-	ctx, cancel := d.Ctx(5)
+	return d.DeleteManyContext(context.Background(), filter, opts...)
+}
+
+// DeleteManyContext deletes filtered rows
+func (d *DAOMg) DeleteManyContext(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	defer d.observe("delete_many", time.Now())
+	ctx, cancel := d.CtxFrom(ctx, 5)
 	defer cancel()
 	return d.C().DeleteMany(ctx, filter, opts...)
 }
@@ -246,7 +355,27 @@ func (d *DAOMg) C() *mongo.Collection {
 
 // Ctx creates new timeout context
 func (d *DAOMg) Ctx(seconds uint) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), time.Duration(seconds)*time.Second)
+	return d.CtxFrom(context.Background(), seconds)
+}
+
+// CtxFrom creates new timeout context derived from the parent one.
+// The parent's cancellation and deadline, if earlier, are respected.
+func (d *DAOMg) CtxFrom(parent context.Context, seconds uint) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, time.Duration(seconds)*time.Second)
+}
+
+// observe records the operation latency, see daoLatencyMetric
+func (d *DAOMg) observe(operation string, start time.Time) {
+	daoLatencyMetric.Observe(time.Since(start).Seconds(), d.collectionName(), operation)
+}
+
+// collectionName returns the collection name, `_unknown_` if not set
+func (d *DAOMg) collectionName() string {
+	c := d.C()
+	if c == nil {
+		return "_unknown_"
+	}
+	return c.Name()
 }
 
 // Err transforms and log an error if needed
@@ -262,12 +391,7 @@ func (d *DAOMg) Err(err error) error {
 		needLog = false
 	}
 	if needLog {
-		cName := "_unknown_"
-		c := d.C()
-		if c != nil {
-			cName = c.Name()
-		}
-		log.WithField("dao", cName).Error(err)
+		log.WithField("dao", d.collectionName()).Error(err)
 	}
 	return err
 }
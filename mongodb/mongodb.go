@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -16,9 +17,14 @@ type Options struct {
 	Password string
 }
 
-// NewMongoDB creates MongoDB instance
+// NewMongoDB creates MongoDB instance, see NewMongoDBContext
 func NewMongoDB(opt *Options) (*MongoDB, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	return NewMongoDBContext(context.Background(), opt)
+}
+
+// NewMongoDBContext creates MongoDB instance, connecting within the ctx and 2 seconds timeout
+func NewMongoDBContext(ctx context.Context, opt *Options) (*MongoDB, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
 	var err error
@@ -59,10 +65,23 @@ type MongoDB struct {
 	Db     *mongo.Database
 }
 
-// Close closes db connection context
+// Close closes db connection context, see CloseContext
 func (m *MongoDB) Close() error {
+	return m.CloseContext(context.Background())
+}
+
+// CloseContext closes db connection context, waiting for in-use connections until the ctx is done
+func (m *MongoDB) CloseContext(ctx context.Context) error {
 	if m.client != nil {
-		return m.client.Disconnect(context.Background())
+		return m.client.Disconnect(ctx)
 	}
 	return nil
 }
+
+// Ping checks if the primary server is reachable
+func (m *MongoDB) Ping(ctx context.Context) error {
+	if m.client == nil {
+		return errors.New("mongo client is not initialized")
+	}
+	return m.client.Ping(ctx, readpref.Primary())
+}
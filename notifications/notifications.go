@@ -1,14 +1,10 @@
 package notifications
 
 import (
-	"bytes"
+	"context"
 	"github.com/bubulearn/bubucore"
-	jsoniter "github.com/json-iterator/go"
-	"io"
-	"io/ioutil"
+	"github.com/bubulearn/bubucore/httpclient"
 	"net/http"
-	"strings"
-	"time"
 )
 
 // notification-service endpoints
@@ -21,11 +17,20 @@ const (
 	EndpointAmoCRMLead       = "amocrm/lead"
 )
 
+// logTag is a notifications errors prefix
+const logTag = "[bubucore][notifications]"
+
 // NewClient creates new notifications service client
 func NewClient(host string, token string) *Client {
 	return &Client{
 		host:  host,
 		token: token,
+		client: httpclient.New(httpclient.Options{
+			Name:    "notifications",
+			BaseURL: host,
+			LogTag:  logTag,
+			Auth:    httpclient.BearerAuth(token),
+		}),
 	}
 }
 
@@ -34,159 +39,131 @@ type Client struct {
 	host  string
 	token string
 
-	_client *http.Client
+	client *httpclient.Client
 }
 
-// Ping pings notifications host
-func (c *Client) Ping() error {
-	err := c.checkPreconditions()
-	if err != nil {
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodGet, c.host, nil)
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-type", "application/json")
-
-	resp, err := c.client().Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	if resp.StatusCode == http.StatusOK {
-		return nil
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
+// HTTP returns the underlying httpclient.Client
+func (c *Client) HTTP() *httpclient.Client {
+	return c.client
+}
 
-	respErr := &bubucore.Error{}
-	err = jsoniter.Unmarshal(body, &respErr)
-	if err != nil {
-		return bubucore.NewError(http.StatusBadGateway, "failed to send notification and to decode response: "+string(body))
-	}
+// Host returns notifications service host
+func (c *Client) Host() string {
+	return c.host
+}
 
-	return respErr
+// Ping pings notifications host, see PingContext
+func (c *Client) Ping() error {
+	return c.PingContext(context.Background())
 }
 
-// Send sends notification request
-func (c *Client) Send(endpoint string, data interface{}) error {
+// PingContext pings notifications host
+func (c *Client) PingContext(ctx context.Context) error {
 	err := c.checkPreconditions()
 	if err != nil {
 		return err
 	}
+	return c.client.DoJSON(ctx, http.MethodGet, "", nil, nil)
+}
 
-	endpoint = "/" + strings.TrimLeft(endpoint, "/")
-
-	var bodyReader io.Reader
-	if data != nil {
-		body, err := jsoniter.Marshal(data)
-		if err != nil {
-			return err
-		}
-		bodyReader = bytes.NewBuffer(body)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.host+endpoint, bodyReader)
-	if err != nil {
-		return err
-	}
-
-	agentParts := []string{"go-http"}
-	if bubucore.Opt != nil {
-		if bubucore.Opt.ServiceName != "" {
-			agentParts = append(agentParts, bubucore.Opt.ServiceName)
-		}
-		if bubucore.Opt.APIVersion != "" {
-			agentParts = append(agentParts, bubucore.Opt.APIVersion)
-		}
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Content-type", "application/json")
-	req.Header.Set("User-Agent", strings.Join(agentParts, "; "))
-
-	resp, err := c.client().Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	if resp.StatusCode == http.StatusOK {
-		return nil
-	}
+// Send sends notification request, see SendContext
+func (c *Client) Send(endpoint string, data interface{}) error {
+	return c.SendContext(context.Background(), endpoint, data)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// SendContext sends notification request
+func (c *Client) SendContext(ctx context.Context, endpoint string, data interface{}) error {
+	err := c.checkPreconditions()
 	if err != nil {
 		return err
 	}
-
-	respErr := &bubucore.Error{}
-	err = jsoniter.Unmarshal(body, &respErr)
-	if err != nil {
-		return bubucore.NewError(http.StatusBadGateway, "failed to send notification and to decode response: "+string(body))
-	}
-
-	return respErr
+	return c.client.DoJSON(ctx, http.MethodPost, endpoint, data, nil)
 }
 
-// SendPlainText sends plain message notification
+// SendPlainText sends plain message notification, see SendPlainTextContext
 func (c *Client) SendPlainText(endpoint string, msg string) error {
-	return c.Send(endpoint, &PlainText{Text: msg})
+	return c.SendPlainTextContext(context.Background(), endpoint, msg)
 }
 
-// SendEmail send custom email notification
+// SendPlainTextContext sends plain message notification
+func (c *Client) SendPlainTextContext(ctx context.Context, endpoint string, msg string) error {
+	return c.SendContext(ctx, endpoint, &PlainText{Text: msg})
+}
+
+// SendEmail send custom email notification, see SendEmailContext
 func (c *Client) SendEmail(n Email) error {
-	return c.Send(EndpointCustomEmail, n)
+	return c.SendEmailContext(context.Background(), n)
+}
+
+// SendEmailContext send custom email notification
+func (c *Client) SendEmailContext(ctx context.Context, n Email) error {
+	return c.SendContext(ctx, EndpointCustomEmail, n)
 }
 
-// SendAppReport sends notification about app report
+// SendAppReport sends notification about app report, see SendAppReportContext
 func (c *Client) SendAppReport(msg string) error {
-	return c.SendPlainText(EndpointAppReport, msg)
+	return c.SendAppReportContext(context.Background(), msg)
 }
 
-// SendPushNotification sends push notification
+// SendAppReportContext sends notification about app report
+func (c *Client) SendAppReportContext(ctx context.Context, msg string) error {
+	return c.SendPlainTextContext(ctx, EndpointAppReport, msg)
+}
+
+// SendPushNotification sends push notification, see SendPushNotificationContext
 func (c *Client) SendPushNotification(push PushNotification) error {
-	return c.Send(EndpointPushNotification, push)
+	return c.SendPushNotificationContext(context.Background(), push)
 }
 
-// SendSMS sends SMS
+// SendPushNotificationContext sends push notification
+func (c *Client) SendPushNotificationContext(ctx context.Context, push PushNotification) error {
+	return c.SendContext(ctx, EndpointPushNotification, push)
+}
+
+// SendSMS sends SMS, see SendSMSContext
 func (c *Client) SendSMS(sms SMS) error {
-	return c.Send(EndpointSMS, sms)
+	return c.SendSMSContext(context.Background(), sms)
 }
 
-// SendAppEvent sends notification to the message broken about some backend app event
+// SendSMSContext sends SMS
+func (c *Client) SendSMSContext(ctx context.Context, sms SMS) error {
+	return c.SendContext(ctx, EndpointSMS, sms)
+}
+
+// SendAppEvent sends notification to the message broken about some backend app event, see SendAppEventContext
 func (c *Client) SendAppEvent(eventName string, eventData interface{}) error {
+	return c.SendAppEventContext(context.Background(), eventName, eventData)
+}
+
+// SendAppEventContext sends notification to the message broken about some backend app event
+func (c *Client) SendAppEventContext(ctx context.Context, eventName string, eventData interface{}) error {
 	event := NewAppEvent(eventName, eventData)
-	return c.SendCustomAppEvent(*event)
+	return c.SendCustomAppEventContext(ctx, *event)
 }
 
-// SendCustomAppEvent sends notification to the message broken about some backend app event
+// SendCustomAppEvent sends notification to the message broken about some backend app event, see SendCustomAppEventContext
 func (c *Client) SendCustomAppEvent(event AppEvent) error {
-	return c.Send(EndpointAppEvent, event)
+	return c.SendCustomAppEventContext(context.Background(), event)
 }
 
-// SendAmoCRMLead send new lead to the AmoCRM
+// SendCustomAppEventContext sends notification to the message broken about some backend app event
+func (c *Client) SendCustomAppEventContext(ctx context.Context, event AppEvent) error {
+	return c.SendContext(ctx, EndpointAppEvent, event)
+}
+
+// SendAmoCRMLead send new lead to the AmoCRM, see SendAmoCRMLeadContext
 func (c *Client) SendAmoCRMLead(req AmoCRMAddLeadReq) error {
-	return c.Send(EndpointAmoCRMLead, req)
+	return c.SendAmoCRMLeadContext(context.Background(), req)
+}
+
+// SendAmoCRMLeadContext send new lead to the AmoCRM
+func (c *Client) SendAmoCRMLeadContext(ctx context.Context, req AmoCRMAddLeadReq) error {
+	return c.SendContext(ctx, EndpointAmoCRMLead, req)
 }
 
 // Close finalizes the Client
 func (c *Client) Close() error {
-	if c._client != nil {
-		c._client.CloseIdleConnections()
-	}
-	return nil
+	return c.client.Close()
 }
 
 // checkPreconditions validates if Client data is ok
@@ -199,13 +176,3 @@ func (c *Client) checkPreconditions() error {
 	}
 	return nil
 }
-
-// client returns http.Client instance
-func (c *Client) client() *http.Client {
-	if c._client == nil {
-		c._client = &http.Client{
-			Timeout: 10 * time.Second,
-		}
-	}
-	return c._client
-}
@@ -2,14 +2,12 @@ package staticservice
 
 import (
 	"bytes"
+	"context"
 	"github.com/bubulearn/bubucore"
-	jsoniter "github.com/json-iterator/go"
-	"io/ioutil"
+	"github.com/bubulearn/bubucore/httpclient"
 	"mime/multipart"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
 const logTag = "[bubucore][staticservice]"
@@ -30,6 +28,12 @@ func NewClient(host string, sign string) *Client {
 	return &Client{
 		host: host,
 		sign: sign,
+		client: httpclient.New(httpclient.Options{
+			Name:    "staticservice",
+			BaseURL: host,
+			LogTag:  logTag,
+			Auth:    httpclient.BearerAuth(sign),
+		}),
 	}
 }
 
@@ -38,12 +42,22 @@ type Client struct {
 	host string
 	sign string
 
-	_client *http.Client
+	client *httpclient.Client
 }
 
-// GetAll returns all uploads
+// HTTP returns the underlying httpclient.Client
+func (c *Client) HTTP() *httpclient.Client {
+	return c.client
+}
+
+// GetAll returns all uploads, see GetAllContext
 func (c *Client) GetAll() (uploads []*Upload, err error) {
-	err = c.DoJSONRequest(http.MethodGet, endpointUploads, nil, &uploads)
+	return c.GetAllContext(context.Background())
+}
+
+// GetAllContext returns all uploads
+func (c *Client) GetAllContext(ctx context.Context) (uploads []*Upload, err error) {
+	err = c.DoJSONRequestContext(ctx, http.MethodGet, endpointUploads, nil, &uploads)
 	if err != nil {
 		return nil, err
 	}
@@ -51,9 +65,14 @@ func (c *Client) GetAll() (uploads []*Upload, err error) {
 	return uploads, nil
 }
 
-// GetUploadInfo fetches upload info by upload ID
+// GetUploadInfo fetches upload info by upload ID, see GetUploadInfoContext
 func (c *Client) GetUploadInfo(uploadID string) (upload *Upload, err error) {
-	err = c.DoJSONRequest(http.MethodGet, endpointUpload+"/"+uploadID, nil, &upload)
+	return c.GetUploadInfoContext(context.Background(), uploadID)
+}
+
+// GetUploadInfoContext fetches upload info by upload ID
+func (c *Client) GetUploadInfoContext(ctx context.Context, uploadID string) (upload *Upload, err error) {
+	err = c.DoJSONRequestContext(ctx, http.MethodGet, endpointUpload+"/"+uploadID, nil, &upload)
 	if err != nil {
 		return nil, err
 	}
@@ -61,8 +80,13 @@ func (c *Client) GetUploadInfo(uploadID string) (upload *Upload, err error) {
 	return upload, nil
 }
 
-// Upload sends file upload request
+// Upload sends file upload request, see UploadContext
 func (c *Client) Upload(title string, filename string, data []byte, ttl uint64) (upload *Upload, err error) {
+	return c.UploadContext(context.Background(), title, filename, data, ttl)
+}
+
+// UploadContext sends file upload request
+func (c *Client) UploadContext(ctx context.Context, title string, filename string, data []byte, ttl uint64) (upload *Upload, err error) {
 	err = c.checkPreconditions()
 	if err != nil {
 		return nil, err
@@ -94,7 +118,7 @@ func (c *Client) Upload(title string, filename string, data []byte, ttl uint64)
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, c.host+endpointUpload, body)
+	req, err := c.client.NewRequest(ctx, http.MethodPost, endpointUpload, body)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +127,7 @@ func (c *Client) Upload(title string, filename string, data []byte, ttl uint64)
 
 	var upl *Upload
 
-	err = c.doRequest(req, &upl)
+	err = c.client.DoRequest(req, &upl)
 	if err != nil {
 		return nil, err
 	}
@@ -113,65 +137,21 @@ func (c *Client) Upload(title string, filename string, data []byte, ttl uint64)
 
 // Close the Client
 func (c *Client) Close() error {
-	if c._client != nil {
-		c._client.CloseIdleConnections()
-	}
-	return nil
+	return c.client.Close()
 }
 
-// DoJSONRequest sends request to the users service and decodes response to the respData
-func (c *Client) DoJSONRequest(method string, endpoint string, reqData interface{}, respData interface{}) (err error) {
-	err = c.checkPreconditions()
-	if err != nil {
-		return err
-	}
-
-	endpoint = "/" + strings.TrimLeft(endpoint, "/")
-
-	body := []byte("")
-	if reqData != nil {
-		body, err = jsoniter.Marshal(reqData)
-		if err != nil {
-			return err
-		}
-	}
-
-	req, err := http.NewRequest(method, c.host+endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-type", "application/json")
-
-	return c.doRequest(req, respData)
+// DoJSONRequest sends request to the static service and decodes response to the respData, see DoJSONRequestContext
+func (c *Client) DoJSONRequest(method string, endpoint string, reqData interface{}, respData interface{}) error {
+	return c.DoJSONRequestContext(context.Background(), method, endpoint, reqData, respData)
 }
 
-func (c *Client) doRequest(req *http.Request, respData interface{}) (err error) {
-	req.Header.Set("Authorization", "Bearer "+c.sign)
-
-	resp, err := c.client().Do(req)
+// DoJSONRequestContext sends request to the static service and decodes response to the respData
+func (c *Client) DoJSONRequestContext(ctx context.Context, method string, endpoint string, reqData interface{}, respData interface{}) error {
+	err := c.checkPreconditions()
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return bubucore.NewError(resp.StatusCode, logTag+" non-OK response: "+string(body))
-	}
-
-	err = jsoniter.Unmarshal(body, respData)
-	if err != nil {
-		return bubucore.NewError(http.StatusBadGateway, logTag+" failed to decode response: "+string(body))
-	}
-
-	return nil
+	return c.client.DoJSON(ctx, method, endpoint, reqData, respData)
 }
 
 // checkPreconditions validates if Client data is ok
@@ -184,13 +164,3 @@ func (c *Client) checkPreconditions() error {
 	}
 	return nil
 }
-
-// client returns http.Client instance
-func (c *Client) client() *http.Client {
-	if c._client == nil {
-		c._client = &http.Client{
-			Timeout: 10 * time.Second,
-		}
-	}
-	return c._client
-}
@@ -0,0 +1,58 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// AlgEdDSA is the EdDSA JWT algorithm name
+const AlgEdDSA = "EdDSA"
+
+// ErrEdDSAVerification is returned when the EdDSA signature is invalid
+var ErrEdDSAVerification = errors.New(logTag + "EdDSA verification failed")
+
+// SigningMethodEdDSA is the Ed25519 JWT signing method, not provided by jwt-go v3
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+// registers the EdDSA signing method to parse tokens with
+func init() {
+	jwt.RegisterSigningMethod(AlgEdDSA, func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// signingMethodEdDSA implements the jwt.SigningMethod with the Ed25519 keys
+type signingMethodEdDSA struct{}
+
+// Alg returns the algorithm name
+func (m *signingMethodEdDSA) Alg() string {
+	return AlgEdDSA
+}
+
+// Verify verifies the signature with the ed25519.PublicKey
+func (m *signingMethodEdDSA) Verify(signingString string, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok || len(pub) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return ErrEdDSAVerification
+	}
+	return nil
+}
+
+// Sign signs the signing string with the ed25519.PrivateKey
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok || len(priv) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
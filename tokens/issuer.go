@@ -0,0 +1,189 @@
+package tokens
+
+import (
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/users"
+	"github.com/bubulearn/bubucore/utils"
+	"github.com/dgrijalva/jwt-go"
+	"sync"
+	"time"
+)
+
+// Issuer defaults
+const (
+	accessTTLDft  = 15 * time.Minute
+	refreshTTLDft = 30 * 24 * time.Hour
+)
+
+// IssuerOptions are the Issuer options
+type IssuerOptions struct {
+	// AccessTTL is an access token TTL, 15 minutes by default
+	AccessTTL time.Duration
+
+	// RefreshTTL is a refresh token TTL, 30 days by default
+	RefreshTTL time.Duration
+
+	// Issuer is the `iss` claim, optional
+	Issuer string
+
+	// Audience is the `aud` claim, optional
+	Audience string
+
+	// ServicesAllowed are the services allowed to use the access tokens with, all services if empty.
+	// Refresh tokens are not restricted, so the issuing service can parse them.
+	ServicesAllowed []string
+
+	// Key is a private key to sign tokens with, the kid header is set to its ID.
+	// Tokens are signed with HS256 and the bubucore.Opt.JWTPassword if nil.
+	Key *Key
+}
+
+// TokenPair is a linked pair of the access and refresh tokens
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+
+	// ExpiresIn is the access token TTL in seconds
+	ExpiresIn int64 `json:"expires_in"`
+
+	AccessClaims  *AccessTokenClaims  `json:"-"`
+	RefreshClaims *RefreshTokenClaims `json:"-"`
+}
+
+// NewIssuer creates new Issuer instance
+func NewIssuer(opt IssuerOptions) (*Issuer, error) {
+	if opt.AccessTTL <= 0 {
+		opt.AccessTTL = accessTTLDft
+	}
+	if opt.RefreshTTL <= 0 {
+		opt.RefreshTTL = refreshTTLDft
+	}
+	i := &Issuer{opt: opt}
+	if err := i.SetKey(opt.Key); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Issuer mints linked access and refresh tokens pairs. Safe for concurrent use.
+type Issuer struct {
+	opt IssuerOptions
+
+	mu  sync.RWMutex
+	key *Key
+}
+
+// SetKey sets the private key to sign tokens with, HS256 and the bubucore.Opt.JWTPassword if nil.
+// Publish the new key to the verifiers before switching to it to rotate keys.
+func (i *Issuer) SetKey(key *Key) error {
+	if key != nil {
+		if key.Private == nil {
+			return errors.New(logTag + "private key is required to sign tokens with the key " + key.ID)
+		}
+		if key.ID == "" {
+			return errors.New(logTag + "key ID is required")
+		}
+	}
+	i.mu.Lock()
+	i.key = key
+	i.mu.Unlock()
+	return nil
+}
+
+// Key returns the signing key, nil in the HMAC mode
+func (i *Issuer) Key() *Key {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.key
+}
+
+// Issue mints the access and refresh tokens pair of the user.
+// The tokens IDs are new UUIDs linked with the `rti` and `ati` claims.
+// Returns bubucore.ErrUserBlocked for the blocked user.
+func (i *Issuer) Issue(user *users.User) (*TokenPair, error) {
+	if user == nil || !utils.ValidateUUID(user.ID) {
+		return nil, errors.New(logTag + "user with the UUID is required")
+	}
+	if err := users.ValidateRole(user.Role); err != nil {
+		return nil, err
+	}
+	if user.IsBlocked {
+		return nil, bubucore.ErrUserBlocked
+	}
+
+	accessID := utils.GenerateUUID()
+	refreshID := utils.GenerateUUID()
+	for refreshID == accessID {
+		refreshID = utils.GenerateUUID()
+	}
+
+	now := time.Now()
+
+	access := &AccessTokenClaims{
+		TokenClaimsDft:  i.claims(user.ID, accessID, now, i.opt.AccessTTL, i.opt.ServicesAllowed),
+		Role:            user.Role,
+		Name:            user.Name,
+		RefreshTokenID:  refreshID,
+		Language:        user.Language,
+		LessonsLanguage: user.LessonsLanguage,
+	}
+	refresh := &RefreshTokenClaims{
+		TokenClaimsDft: i.claims(user.ID, refreshID, now, i.opt.RefreshTTL, nil),
+		AccessTokenID:  accessID,
+	}
+
+	pair := &TokenPair{
+		ExpiresIn:     int64(i.opt.AccessTTL.Seconds()),
+		AccessClaims:  access,
+		RefreshClaims: refresh,
+	}
+
+	var err error
+	pair.AccessToken, err = i.sign(access)
+	if err != nil {
+		return nil, err
+	}
+	pair.RefreshToken, err = i.sign(refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// claims returns the common claims of the token
+func (i *Issuer) claims(userID string, tokenID string, now time.Time, ttl time.Duration, services []string) TokenClaimsDft {
+	return TokenClaimsDft{
+		UserID:          userID,
+		ServicesAllowed: services,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Subject:   userID,
+			Issuer:    i.opt.Issuer,
+			Audience:  i.opt.Audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+}
+
+// sign signs the claims with the Issuer's key
+func (i *Issuer) sign(claims jwt.Claims) (string, error) {
+	key := i.Key()
+	if key == nil {
+		password := bubucore.Opt.GetJWTPassword()
+		if len(password) == 0 {
+			return "", errors.New(logTag + "JWT password is required to sign tokens with")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(password)
+	}
+
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", errors.New(logTag + "unsupported algorithm " + key.Algorithm)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
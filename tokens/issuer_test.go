@@ -0,0 +1,165 @@
+package tokens_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/i18n"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/bubulearn/bubucore/users"
+	"github.com/bubulearn/bubucore/utils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIssuer_Issue(t *testing.T) {
+	initial := bubucore.Opt.JWTPassword
+	bubucore.Opt.JWTPassword = []byte("test")
+	defer func() { bubucore.Opt.JWTPassword = initial }()
+
+	issuer, err := tokens.NewIssuer(tokens.IssuerOptions{
+		AccessTTL: 5 * time.Minute,
+		Issuer:    "auth",
+		Audience:  "bubulearn",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	user := &users.User{
+		ID:              "03a4e59c-fb22-4bfa-8739-8062bcdd2005",
+		Role:            users.RoleTeacher,
+		Name:            "John Doe",
+		Language:        i18n.LangRu,
+		LessonsLanguage: i18n.LangEn,
+	}
+	pair, err := issuer.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.EqualValues(t, 300, pair.ExpiresIn)
+
+	access, err := tokens.ParseAccessToken(pair.AccessToken)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, user.ID, access.GetUserID())
+	assert.Equal(t, users.RoleTeacher, access.Role)
+	assert.Equal(t, "John Doe", access.Name)
+	assert.Equal(t, i18n.LangRu, access.Language)
+	assert.Equal(t, i18n.LangEn, access.LessonsLanguage)
+	assert.Equal(t, "auth", access.Issuer)
+	assert.Equal(t, "bubulearn", access.Audience)
+	assert.InDelta(t, time.Now().Add(5*time.Minute).Unix(), access.ExpiresAt, 1)
+
+	refresh := &tokens.RefreshTokenClaims{}
+	_, err = jwt.ParseWithClaims(pair.RefreshToken, refresh, func(*jwt.Token) (interface{}, error) {
+		return bubucore.Opt.JWTPassword, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, user.ID, refresh.GetUserID())
+	assert.True(t, utils.ValidateUUID(access.GetTokenID()))
+	assert.True(t, utils.ValidateUUID(refresh.GetTokenID()))
+	assert.NotEqual(t, access.GetTokenID(), refresh.GetTokenID())
+	assert.Equal(t, refresh.GetTokenID(), access.GetRelatedTokenID())
+	assert.Equal(t, access.GetTokenID(), refresh.GetRelatedTokenID())
+	assert.InDelta(t, time.Now().Add(30*24*time.Hour).Unix(), refresh.ExpiresAt, 1)
+	assert.Equal(t, access, pair.AccessClaims)
+
+	// a new pair on each issue
+	next, err := issuer.Issue(user)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, pair.AccessClaims.GetTokenID(), next.AccessClaims.GetTokenID())
+	}
+
+	invalid := []*users.User{
+		nil,
+		{ID: "invalid", Role: users.RoleStudent},
+		{ID: user.ID, Role: 42},
+		{ID: user.ID, Role: users.RoleStudent, IsBlocked: true},
+	}
+	for _, u := range invalid {
+		_, err = issuer.Issue(u)
+		assert.Error(t, err)
+	}
+
+	bubucore.Opt.JWTPassword = nil
+	_, err = issuer.Issue(user)
+	assert.Error(t, err)
+}
+
+func TestIssuer_Key(t *testing.T) {
+	defer tokens.SetKeyProvider(nil)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, err := tokens.NewKey("ed-1", priv, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	public, err := tokens.NewKey("ed-1", priv.Public(), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = tokens.NewIssuer(tokens.IssuerOptions{Key: public})
+	assert.Error(t, err)
+
+	issuer, err := tokens.NewIssuer(tokens.IssuerOptions{
+		Key:             key,
+		ServicesAllowed: []string{"lessons"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	pair, err := issuer.Issue(&users.User{ID: "03a4e59c-fb22-4bfa-8739-8062bcdd2005", Role: users.RoleStudent})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	set, err := tokens.NewKeySet(public)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tokens.SetKeyProvider(set)
+
+	initial := bubucore.Opt.ServiceName
+	defer func() { bubucore.Opt.ServiceName = initial }()
+
+	bubucore.Opt.ServiceName = "lessons"
+	claims, err := tokens.ParseAccessToken(pair.AccessToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"lessons"}, claims.GetAllowedServices())
+	}
+
+	bubucore.Opt.ServiceName = "billing"
+	_, err = tokens.ParseAccessToken(pair.AccessToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+
+	// the issuing service refreshes the restricted tokens
+	bubucore.Opt.ServiceName = "auth"
+	refresh, err := tokens.ParseRefreshToken(pair.RefreshToken)
+	if assert.NoError(t, err) {
+		assert.Empty(t, refresh.GetAllowedServices())
+	}
+
+	user := &users.User{ID: "03a4e59c-fb22-4bfa-8739-8062bcdd2005", Role: users.RoleStudent}
+	store := tokens.NewMemoryRefreshStore(issuer, func(ctx context.Context, userID string) (*users.User, error) {
+		return user, nil
+	})
+	pair, err = store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	next, err := store.Refresh(pair.RefreshToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"lessons"}, next.AccessClaims.GetAllowedServices())
+	}
+}
@@ -0,0 +1,247 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/httpclient"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySource defaults
+const (
+	keySourceTTLDft             = 5 * time.Minute
+	keySourceRefreshIntervalDft = 30 * time.Second
+	keySourceTimeoutDft         = 5 * time.Second
+)
+
+// KeySourceOptions are the KeySource options
+type KeySourceOptions struct {
+	// TTL is a keys cache TTL if the JWKS response has no Cache-Control max-age, 5 minutes by default
+	TTL time.Duration
+
+	// MinRefreshInterval is a minimum interval between the JWKS requests
+	// on the unknown kid, expired cache or failures, 30 seconds by default
+	MinRefreshInterval time.Duration
+
+	// Timeout is a JWKS request timeout, 5 seconds by default
+	Timeout time.Duration
+
+	// Client is a JWKS requests client, optional
+	Client *httpclient.Client
+}
+
+// NewKeySource creates new KeySource instance fetching keys from the JWKS URL.
+// Keys are fetched lazily on the first Key call, use Refresh to fetch them in advance.
+func NewKeySource(url string, opt KeySourceOptions) *KeySource {
+	if opt.TTL <= 0 {
+		opt.TTL = keySourceTTLDft
+	}
+	if opt.MinRefreshInterval <= 0 {
+		opt.MinRefreshInterval = keySourceRefreshIntervalDft
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = keySourceTimeoutDft
+	}
+	if opt.Client == nil {
+		opt.Client = httpclient.New(httpclient.Options{
+			Name:    "jwks",
+			LogTag:  "[bubucore.tokens]",
+			Timeout: opt.Timeout,
+		})
+	}
+	return &KeySource{
+		url: url,
+		opt: opt,
+	}
+}
+
+// KeySource is a KeyProvider caching keys fetched from the JWKS URL.
+// The cached keys are refreshed when expired or on the unknown kid, not more often than the MinRefreshInterval,
+// and are served stale while the JWKS URL is unavailable. Safe for concurrent use.
+type KeySource struct {
+	url string
+	opt KeySourceOptions
+
+	mu          sync.RWMutex
+	keys        *KeySet
+	expires     time.Time
+	lastAttempt time.Time
+
+	refreshMu sync.Mutex
+}
+
+// URL returns the JWKS URL
+func (s *KeySource) URL() string {
+	return s.url
+}
+
+// Key returns the key by ID, see KeyContext
+func (s *KeySource) Key(kid string) (*Key, error) {
+	return s.KeyContext(context.Background(), kid)
+}
+
+// KeyContext returns the key by ID, refreshing the keys if they are expired or the key is unknown.
+// Returns ErrKeyNotFound if no such key, bubucore.ErrKeysUnavailable if no keys fetched yet.
+func (s *KeySource) KeyContext(ctx context.Context, kid string) (*Key, error) {
+	keys, expired := s.cached()
+	if keys == nil || expired {
+		s.refresh(ctx, false)
+		keys, _ = s.cached()
+	}
+	if keys == nil {
+		return nil, bubucore.ErrKeysUnavailable
+	}
+
+	key, err := keys.Key(kid)
+	if errors.Is(err, ErrKeyNotFound) && s.refresh(ctx, true) {
+		keys, _ = s.cached()
+		key, err = keys.Key(kid)
+	}
+	return key, err
+}
+
+// Keys returns the cached keys, nil if no keys fetched yet
+func (s *KeySource) Keys() *KeySet {
+	keys, _ := s.cached()
+	return keys
+}
+
+// Expires returns the cached keys expiration time
+func (s *KeySource) Expires() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expires
+}
+
+// Close closes the JWKS client idle connections, the cached keys are still served
+func (s *KeySource) Close() error {
+	return s.opt.Client.Close()
+}
+
+// Refresh fetches the keys from the JWKS URL regardless of the cache state.
+// The cached keys are kept on failure.
+func (s *KeySource) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	keys, ttl, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.expires = time.Now().Add(ttl)
+	s.mu.Unlock()
+	return nil
+}
+
+// cached returns the cached keys and whether they are expired
+func (s *KeySource) cached() (*KeySet, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys, !time.Now().Before(s.expires)
+}
+
+// refresh refreshes the keys if the MinRefreshInterval has passed since the last attempt,
+// and if they are still expired unless forced. Failures are logged, the stale keys are kept.
+// Returns true if the keys are refreshed.
+func (s *KeySource) refresh(ctx context.Context, force bool) bool {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	keys, expires, lastAttempt := s.keys, s.expires, s.lastAttempt
+	s.mu.RUnlock()
+
+	if time.Since(lastAttempt) < s.opt.MinRefreshInterval {
+		return false
+	}
+	if !force && keys != nil && time.Now().Before(expires) {
+		return false
+	}
+
+	err := s.Refresh(ctx)
+	if err != nil {
+		if keys != nil {
+			log.Warn(logTag, "failed to refresh JWKS, serving stale keys: ", err)
+		} else {
+			log.Error(logTag, "failed to fetch JWKS: ", err)
+		}
+		return false
+	}
+	return true
+}
+
+// fetch requests the JWKS URL, returns the keys and their TTL
+func (s *KeySource) fetch(ctx context.Context) (*KeySet, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opt.Timeout)
+	defer cancel()
+
+	req, err := s.opt.Client.NewRequest(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+	resp, err := s.opt.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, s.opt.Client.DecodeError(resp.StatusCode, body)
+	}
+
+	parsed, err := ParseJWKS(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(parsed) == 0 {
+		return nil, 0, errors.New(logTag + "no signing keys in JWKS " + s.url)
+	}
+	keys, err := NewKeySet(parsed...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl, ok := cacheMaxAge(resp.Header.Get("Cache-Control"))
+	if !ok {
+		ttl = s.opt.TTL
+	}
+	return keys, ttl, nil
+}
+
+// cacheMaxAge returns the Cache-Control header max-age, zero for no-cache and no-store.
+// Returns false if the header defines none of them.
+func cacheMaxAge(header string) (time.Duration, bool) {
+	maxAge, found := time.Duration(0), false
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			sec, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+			if err == nil && sec >= 0 {
+				maxAge, found = time.Duration(sec)*time.Second, true
+			}
+		}
+	}
+	return maxAge, found
+}
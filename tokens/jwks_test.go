@@ -0,0 +1,211 @@
+package tokens_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/httpclient"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer is a test JWKS endpoint
+type jwksServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	keys         map[string]ed25519.PrivateKey
+	cacheControl string
+	down         bool
+	requests     int32
+}
+
+// newJWKSServer starts new jwksServer serving the public keys of the kids
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	s := &jwksServer{keys: make(map[string]ed25519.PrivateKey)}
+	for _, kid := range kids {
+		s.add(t, kid)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		keys := make([]map[string]string, 0, len(s.keys))
+		for kid, key := range s.keys {
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": kid,
+				"x":   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+			})
+		}
+		if s.cacheControl != "" {
+			w.Header().Set("Cache-Control", s.cacheControl)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// add generates the key of the kid
+func (s *jwksServer) add(t *testing.T, kid string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+}
+
+// sign signs the valid access token with the key of the kid
+func (s *jwksServer) sign(t *testing.T, kid string) string {
+	s.mu.Lock()
+	key := s.keys[kid]
+	s.mu.Unlock()
+	return signAccessToken(t, tokens.AlgEdDSA, kid, key)
+}
+
+// set updates the server state
+func (s *jwksServer) set(cacheControl string, down bool) {
+	s.mu.Lock()
+	s.cacheControl = cacheControl
+	s.down = down
+	s.mu.Unlock()
+}
+
+// newTestKeySource creates KeySource of the server without retries
+func newTestKeySource(url string, interval time.Duration) *tokens.KeySource {
+	return tokens.NewKeySource(url, tokens.KeySourceOptions{
+		MinRefreshInterval: interval,
+		Client: httpclient.New(httpclient.Options{
+			Retry: &httpclient.RetryPolicy{MaxAttempts: 1},
+		}),
+	})
+}
+
+func TestKeySource_Key(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	srv.set("public, max-age=3600", false)
+	src := newTestKeySource(srv.URL, 50*time.Millisecond)
+
+	claims, err := tokens.ParseAccessTokenWith(srv.sign(t, "a"), src)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "03a4e59c-fb22-4bfa-8739-8062bcdd2005", claims.GetUserID())
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), src.Expires(), time.Minute)
+
+	// cached
+	_, err = src.Key("a")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+
+	// unknown kid refresh is rate-limited
+	srv.add(t, "b")
+	_, err = src.Key("b")
+	assert.ErrorIs(t, err, tokens.ErrKeyNotFound)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = tokens.ParseAccessTokenWith(srv.sign(t, "b"), src)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+
+	_, err = src.Key("c")
+	assert.ErrorIs(t, err, tokens.ErrKeyNotFound)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = tokens.ParseAccessTokenWith(signAccessToken(t, tokens.AlgEdDSA, "c", other), src)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+}
+
+// idleTransport counts the idle connections closes
+type idleTransport struct {
+	http.RoundTripper
+	closes int32
+}
+
+func (t *idleTransport) CloseIdleConnections() {
+	atomic.AddInt32(&t.closes, 1)
+}
+
+func TestKeySource_Close(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	tr := &idleTransport{RoundTripper: http.DefaultTransport}
+	src := tokens.NewKeySource(srv.URL, tokens.KeySourceOptions{
+		Client: httpclient.New(httpclient.Options{Transport: tr}),
+	})
+
+	assert.NoError(t, src.Refresh(context.Background()))
+	assert.NoError(t, src.Close())
+	assert.EqualValues(t, 1, atomic.LoadInt32(&tr.closes))
+
+	// the cached keys are still served
+	_, err := src.Key("a")
+	assert.NoError(t, err)
+}
+
+func TestKeySource_Stale(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	srv.set("no-cache", false)
+	src := newTestKeySource(srv.URL, 20*time.Millisecond)
+
+	assert.NoError(t, src.Refresh(context.Background()))
+	assert.False(t, src.Expires().After(time.Now()))
+
+	// expired keys are served stale while the JWKS is unavailable
+	srv.set("", true)
+	time.Sleep(30 * time.Millisecond)
+	_, err := tokens.ParseAccessTokenWith(srv.sign(t, "a"), src)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+	assert.Error(t, src.Refresh(context.Background()))
+
+	// the default TTL is used without Cache-Control
+	srv.set("", false)
+	time.Sleep(30 * time.Millisecond)
+	_, err = src.Key("a")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&srv.requests))
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), src.Expires(), time.Minute)
+}
+
+func TestKeySource_Unavailable(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	srv.set("", true)
+	src := newTestKeySource(srv.URL, time.Minute)
+
+	token := srv.sign(t, "a")
+	_, err := tokens.ParseAccessTokenWith(token, src)
+	assert.ErrorIs(t, err, bubucore.ErrKeysUnavailable)
+	assert.Nil(t, src.Keys())
+
+	// the next attempt is rate-limited
+	_, err = src.Key("a")
+	assert.ErrorIs(t, err, bubucore.ErrKeysUnavailable)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+
+	srv.set("", false)
+	assert.NoError(t, src.Refresh(context.Background()))
+
+	tokens.SetKeyProvider(src)
+	defer tokens.SetKeyProvider(nil)
+	_, err = tokens.ParseAccessToken(token)
+	assert.NoError(t, err)
+}
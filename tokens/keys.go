@@ -0,0 +1,359 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// logTag is a tokens package log tag
+const logTag = "[bubucore.tokens] "
+
+// Key errors
+var (
+	ErrKeyNotFound    = errors.New(logTag + "key not found")
+	ErrKeyUnsupported = errors.New(logTag + "unsupported key")
+)
+
+// asymmetricMethods are the signing methods accepted with the KeyProvider
+var asymmetricMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	AlgEdDSA,
+}
+
+// Key is a JWT signing key identified by the kid header
+type Key struct {
+	// ID is a key ID matched against the token's kid header
+	ID string
+
+	// Algorithm is a JWT signing algorithm the key is used with, e. g. RS256
+	Algorithm string
+
+	// Public is a *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey to verify tokens with
+	Public crypto.PublicKey
+
+	// Private is an optional private key to sign tokens with
+	Private crypto.PrivateKey
+}
+
+// NewKey creates new Key from the public or private key.
+// The algorithm is inferred from the key type if empty:
+// RS256 for RSA keys, ES256/ES384/ES512 for P-256/P-384/P-521 keys and EdDSA for Ed25519 keys.
+func NewKey(kid string, key interface{}, alg string) (*Key, error) {
+	k := &Key{ID: kid}
+
+	switch v := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		k.Private = v
+		k.Public = v.(crypto.Signer).Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		k.Public = v
+	default:
+		return nil, ErrKeyUnsupported
+	}
+
+	algs := keyAlgorithms(k.Public)
+	if len(algs) == 0 {
+		return nil, ErrKeyUnsupported
+	}
+	if alg == "" {
+		alg = algs[0]
+	}
+	for _, a := range algs {
+		if a == alg {
+			k.Algorithm = alg
+			return k, nil
+		}
+	}
+	return nil, errors.New(logTag + "algorithm " + alg + " does not match the key " + kid)
+}
+
+// keyAlgorithms returns the algorithms the public key can be used with, the default one first
+func keyAlgorithms(pub crypto.PublicKey) []string {
+	switch v := pub.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		switch v.Curve {
+		case elliptic.P256():
+			return []string{"ES256"}
+		case elliptic.P384():
+			return []string{"ES384"}
+		case elliptic.P521():
+			return []string{"ES512"}
+		}
+	case ed25519.PublicKey:
+		return []string{AlgEdDSA}
+	}
+	return nil
+}
+
+// ParsePEMKey parses the first key of the PEM data: a PKIX or PKCS #1 public key,
+// a PKCS #1, PKCS #8 or SEC 1 private key, or a certificate.
+// The alg is the key's algorithm, the default one of the key type if empty, see NewKey.
+func ParsePEMKey(kid string, data []byte, alg string) (*Key, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New(logTag + "no PEM key found for the key " + kid)
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(logTag + "failed to parse the key " + kid + ": " + err.Error())
+		}
+		return NewKey(kid, key, alg)
+	}
+}
+
+// jwk is a JSON Web Key, RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// ParseJWKS parses the JSON Web Key Set document.
+// Supports RSA, EC P-256/P-384/P-521 and OKP Ed25519 public keys;
+// keys of other types or not for signatures are skipped.
+func ParseJWKS(data []byte) ([]*Key, error) {
+	doc := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, errors.New(logTag + "failed to parse JWKS: " + err.Error())
+	}
+
+	keys := make([]*Key, 0, len(doc.Keys))
+	for _, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		pub, err := j.publicKey()
+		if errors.Is(err, ErrKeyUnsupported) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, err := NewKey(j.Kid, pub, j.Alg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// publicKey decodes the JWK public key
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err1 := decodeJWKInt(j.N)
+		e, err2 := decodeJWKInt(j.E)
+		if err1 != nil || err2 != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New(logTag + "invalid RSA key " + j.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrKeyUnsupported
+		}
+		x, err1 := decodeJWKInt(j.X)
+		y, err2 := decodeJWKInt(j.Y)
+		if err1 != nil || err2 != nil || !curve.IsOnCurve(x, y) {
+			return nil, errors.New(logTag + "invalid EC key " + j.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, ErrKeyUnsupported
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New(logTag + "invalid Ed25519 key " + j.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrKeyUnsupported
+}
+
+// decodeJWKInt decodes the base64url-encoded big-endian integer
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New(logTag + "empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// LoadKeyFile loads keys from the file: a JWKS document if the file has the .json extension,
+// a PEM key otherwise. The PEM key ID is the kid if not empty, the file base name without extension otherwise.
+// The alg is the PEM key's algorithm, e. g. PS256 for the RSA key, RS256 by default;
+// JWKS keys define their algorithms themselves.
+func LoadKeyFile(kid string, path string, alg string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if alg != "" {
+			return nil, errors.New(logTag + "algorithm of the JWKS keys is defined by the JWKS " + path)
+		}
+		return ParseJWKS(data)
+	}
+
+	if kid == "" {
+		kid = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	key, err := ParsePEMKey(kid, data, alg)
+	if err != nil {
+		return nil, err
+	}
+	return []*Key{key}, nil
+}
+
+// ParseKeySpec parses the key file spec: `path`, `kid=path` or `kid:alg=path`, e. g. `rsa-1:PS256=keys/rsa-1.pem`
+func ParseKeySpec(spec string) (kid string, alg string, path string) {
+	id, path, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", "", spec
+	}
+	kid, alg, _ = strings.Cut(id, ":")
+	return kid, alg, path
+}
+
+// LoadKeySet loads the KeySet from the key files specs, see ParseKeySpec and LoadKeyFile
+func LoadKeySet(files ...string) (*KeySet, error) {
+	keys := make([]*Key, 0, len(files))
+	for _, file := range files {
+		kid, alg, path := ParseKeySpec(file)
+		loaded, err := LoadKeyFile(kid, path, alg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, loaded...)
+	}
+	return NewKeySet(keys...)
+}
+
+// KeyProvider provides keys to verify tokens with by key ID
+type KeyProvider interface {
+	// Key returns the key by ID, ErrKeyNotFound if no such key
+	Key(kid string) (*Key, error)
+}
+
+// NewKeySet creates new KeySet instance, returns an error on the duplicate key IDs
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	s := &KeySet{
+		keys: make([]*Key, 0, len(keys)),
+		byID: make(map[string]*Key, len(keys)),
+	}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New(logTag + "key ID is required")
+		}
+		if _, ok := s.byID[k.ID]; ok {
+			return nil, errors.New(logTag + "duplicate key ID " + k.ID)
+		}
+		s.byID[k.ID] = k
+		s.keys = append(s.keys, k)
+	}
+	return s, nil
+}
+
+// KeySet is an immutable set of keys, several keys may be active at once to rotate them without downtime
+type KeySet struct {
+	keys []*Key
+	byID map[string]*Key
+}
+
+// Key returns the key by ID, ErrKeyNotFound if no such key
+func (s *KeySet) Key(kid string) (*Key, error) {
+	if k, ok := s.byID[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Keys returns all the keys in the order they were added
+func (s *KeySet) Keys() []*Key {
+	return append([]*Key{}, s.keys...)
+}
+
+// keyProvider is a KeyProvider to verify tokens with, see SetKeyProvider
+var keyProvider atomic.Value
+
+// keyProviderBox wraps the KeyProvider to store nil and different types in the atomic.Value
+type keyProviderBox struct {
+	p KeyProvider
+}
+
+// SetKeyProvider sets the KeyProvider to verify tokens signed with the asymmetric algorithms by the kid header.
+// Tokens are verified with the HMAC algorithms and the bubucore.Opt.JWTPassword if the provider is nil.
+func SetKeyProvider(p KeyProvider) {
+	keyProvider.Store(keyProviderBox{p: p})
+}
+
+// GetKeyProvider returns the KeyProvider set, nil in the HMAC mode
+func GetKeyProvider() KeyProvider {
+	box, _ := keyProvider.Load().(keyProviderBox)
+	return box.p
+}
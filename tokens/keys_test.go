@@ -0,0 +1,320 @@
+package tokens_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signAccessToken signs the valid access token claims with the key
+func signAccessToken(t *testing.T, alg string, kid string, key interface{}) string {
+	claims := &tokens.AccessTokenClaims{
+		Role: 10,
+		TokenClaimsDft: tokens.TokenClaimsDft{
+			UserID: "03a4e59c-fb22-4bfa-8739-8062bcdd2005",
+			StandardClaims: jwt.StandardClaims{
+				Id:        "0bf97df4-6246-4809-bdf7-e8d993668283",
+				ExpiresAt: time.Now().Add(10 * time.Minute).Unix(),
+			},
+		},
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestParseAccessToken_KeyProvider(t *testing.T) {
+	defer tokens.SetKeyProvider(nil)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rsaJWK, err := tokens.NewKey("rsa-1", rsaKey, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "RS256", rsaJWK.Algorithm)
+	ecJWK, err := tokens.NewKey("ec-1", ecKey, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "ES384", ecJWK.Algorithm)
+	edJWK, err := tokens.NewKey("ed-1", edKey, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, tokens.AlgEdDSA, edJWK.Algorithm)
+
+	_, err = tokens.NewKey("ec-2", ecKey, "ES256")
+	assert.Error(t, err)
+
+	set, err := tokens.NewKeySet(rsaJWK, ecJWK, edJWK)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tokens.SetKeyProvider(set)
+
+	for _, k := range set.Keys() {
+		claims, err := tokens.ParseAccessToken(signAccessToken(t, k.Algorithm, k.ID, k.Private))
+		if assert.NoError(t, err, k.ID) {
+			assert.Equal(t, "03a4e59c-fb22-4bfa-8739-8062bcdd2005", claims.GetUserID())
+		}
+	}
+
+	invalid := []string{
+		// unknown kid
+		signAccessToken(t, "RS256", "rsa-2", rsaKey),
+		// no kid
+		signAccessToken(t, "RS256", "", rsaKey),
+		// algorithm mismatch
+		signAccessToken(t, "RS512", "rsa-1", rsaKey),
+		// key mismatch
+		signAccessToken(t, "ES384", "rsa-1", ecKey),
+		// HMAC
+		signAccessToken(t, "HS256", "rsa-1", []byte("test")),
+	}
+	for _, v := range invalid {
+		_, err = tokens.ParseAccessToken(v)
+		assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	}
+
+	// rotation: tokens of the old key are valid while both keys are active
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	newJWK, err := tokens.NewKey("rsa-2", newKey, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	oldToken := signAccessToken(t, "RS256", "rsa-1", rsaKey)
+	newToken := signAccessToken(t, "RS256", "rsa-2", newKey)
+
+	set, err = tokens.NewKeySet(rsaJWK, newJWK)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tokens.SetKeyProvider(set)
+	_, err = tokens.ParseAccessToken(oldToken)
+	assert.NoError(t, err)
+	_, err = tokens.ParseAccessToken(newToken)
+	assert.NoError(t, err)
+
+	set, err = tokens.NewKeySet(newJWK)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tokens.SetKeyProvider(set)
+	_, err = tokens.ParseAccessToken(oldToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	_, err = tokens.ParseAccessToken(newToken)
+	assert.NoError(t, err)
+
+	// HMAC tokens are accepted while the JWT password is set during the migration
+	initial := bubucore.Opt.JWTPassword
+	defer func() { bubucore.Opt.JWTPassword = initial }()
+
+	hmacToken := signAccessToken(t, "HS256", "", []byte("test"))
+	bubucore.Opt.JWTPassword = nil
+	_, err = tokens.ParseAccessToken(hmacToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+
+	bubucore.Opt.JWTPassword = []byte("test")
+	_, err = tokens.ParseAccessToken(hmacToken)
+	assert.NoError(t, err)
+	_, err = tokens.ParseAccessToken(signAccessToken(t, "HS256", "", []byte("other")))
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	_, err = tokens.ParseAccessToken(newToken)
+	assert.NoError(t, err)
+
+	// HMAC mode
+	tokens.SetKeyProvider(nil)
+	_, err = tokens.ParseAccessToken(hmacToken)
+	assert.NoError(t, err)
+	_, err = tokens.ParseAccessToken(newToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+}
+
+func TestNewKeySet(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, err := tokens.NewKey("ed", edKey.Public(), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, key.Private)
+
+	_, err = tokens.NewKeySet(key, key)
+	assert.Error(t, err)
+
+	set, err := tokens.NewKeySet(key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = set.Key("other")
+	assert.ErrorIs(t, err, tokens.ErrKeyNotFound)
+
+	_, err = tokens.NewKey("str", "key", "")
+	assert.ErrorIs(t, err, tokens.ErrKeyUnsupported)
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	doc, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "PS256", "n": enc(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X.Bytes()), "y": enc(ecKey.Y.Bytes())},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": enc(edPub)},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": enc(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	keys, err := tokens.ParseJWKS(doc)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, keys, 3) {
+		assert.Equal(t, "PS256", keys[0].Algorithm)
+		assert.True(t, rsaKey.PublicKey.Equal(keys[0].Public))
+		assert.Equal(t, "ES256", keys[1].Algorithm)
+		assert.True(t, ecKey.PublicKey.Equal(keys[1].Public))
+		assert.Equal(t, tokens.AlgEdDSA, keys[2].Algorithm)
+		assert.True(t, edPub.Equal(keys[2].Public))
+	}
+
+	invalid := []string{
+		`{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AQ"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "rsa", "alg": "ES256", "n": "AQAB", "e": "AQAB"}]}`,
+		`{"keys": {}}`,
+	}
+	for _, v := range invalid {
+		_, err = tokens.ParseJWKS([]byte(v))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsaFile := filepath.Join(dir, "rsa-2022.pem")
+	if !assert.NoError(t, os.WriteFile(rsaFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)) {
+		return
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err = x509.MarshalECPrivateKey(ecKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecFile := filepath.Join(dir, "ec.pem")
+	if !assert.NoError(t, os.WriteFile(ecFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)) {
+		return
+	}
+
+	jwksFile := filepath.Join(dir, "jwks.json")
+	jwks := `{"keys": [{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`
+	if !assert.NoError(t, os.WriteFile(jwksFile, []byte(jwks), 0600)) {
+		return
+	}
+
+	set, err := tokens.LoadKeySet(rsaFile, "ec-1="+ecFile, jwksFile, "rsa-ps:PS256="+rsaFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids := make([]string, 0)
+	for _, k := range set.Keys() {
+		ids = append(ids, k.ID)
+	}
+	assert.Equal(t, []string{"rsa-2022", "ec-1", "ed-1", "rsa-ps"}, ids)
+
+	k, err := set.Key("ec-1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ES512", k.Algorithm)
+		assert.NotNil(t, k.Private)
+	}
+
+	k, err = set.Key("rsa-ps")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "PS256", k.Algorithm)
+	}
+	k, err = set.Key("rsa-2022")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "RS256", k.Algorithm)
+	}
+
+	_, err = tokens.LoadKeySet("rsa:ES256=" + rsaFile)
+	assert.Error(t, err)
+	_, err = tokens.LoadKeySet("ed:EdDSA=" + jwksFile)
+	assert.Error(t, err)
+	_, err = tokens.LoadKeySet(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+	_, err = tokens.LoadKeySet(jwksFile, jwksFile)
+	assert.Error(t, err)
+
+	if !assert.NoError(t, os.WriteFile(ecFile, []byte("not a key"), 0600)) {
+		return
+	}
+	_, err = tokens.LoadKeySet(ecFile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,387 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/users"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// RefreshStorage errors
+var (
+	ErrRefreshNotFound = errors.New(logTag + "refresh token is not found")
+	ErrRefreshReplayed = errors.New(logTag + "refresh token is used already")
+)
+
+// memorySweepInterval is an interval to remove expired records from the MemoryRefreshStorage
+const memorySweepInterval = time.Minute
+
+// RefreshRecord is an issued refresh token record
+type RefreshRecord struct {
+	// TokenID is the refresh token ID
+	TokenID string `json:"tid"`
+
+	// FamilyID identifies the tokens rotated from the same login, the first refresh token ID
+	FamilyID string `json:"fid"`
+
+	// UserID is the token's user ID
+	UserID string `json:"uid"`
+
+	// ExpiresAt is the refresh token expiration time
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// RefreshStorage persists the issued refresh tokens records
+type RefreshStorage interface {
+	// Save records the issued refresh token until its expiration.
+	// Returns ErrRefreshNotFound if the family is revoked.
+	Save(ctx context.Context, rec *RefreshRecord) error
+
+	// Use atomically marks the refresh token used and returns its record.
+	// Returns ErrRefreshReplayed with the record if the token is used already,
+	// ErrRefreshNotFound if the token is unknown, expired or revoked.
+	Use(ctx context.Context, tokenID string) (*RefreshRecord, error)
+
+	// Release marks the used refresh token unused again, e. g. if the next pair is not issued
+	Release(ctx context.Context, tokenID string) error
+
+	// RevokeFamily revokes all the tokens of the family
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// RevokeUser revokes all the user's tokens
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// UserFn returns the user by ID, e. g. users.Client.GetUserInfoContext
+type UserFn func(ctx context.Context, userID string) (*users.User, error)
+
+// NewRefreshStore creates new RefreshStore instance.
+// The userFn provides the actual user data for the refreshed access tokens.
+func NewRefreshStore(storage RefreshStorage, issuer *Issuer, userFn UserFn) *RefreshStore {
+	return &RefreshStore{
+		storage: storage,
+		issuer:  issuer,
+		userFn:  userFn,
+	}
+}
+
+// NewMemoryRefreshStore creates new RefreshStore instance with the MemoryRefreshStorage,
+// suitable for a single replica and tests
+func NewMemoryRefreshStore(issuer *Issuer, userFn UserFn) *RefreshStore {
+	return NewRefreshStore(NewMemoryRefreshStorage(), issuer, userFn)
+}
+
+// RefreshStore issues tokens pairs and rotates them on refresh.
+// Each refresh token is usable once: the reuse of the rotated token revokes the whole tokens family.
+type RefreshStore struct {
+	storage RefreshStorage
+	issuer  *Issuer
+	userFn  UserFn
+}
+
+// Storage returns the RefreshStore's storage
+func (s *RefreshStore) Storage() RefreshStorage {
+	return s.storage
+}
+
+// Issue issues the new tokens pair of the user, see IssueContext
+func (s *RefreshStore) Issue(user *users.User) (*TokenPair, error) {
+	return s.IssueContext(context.Background(), user)
+}
+
+// IssueContext issues the tokens pair of the user starting the new tokens family, e. g. on login
+func (s *RefreshStore) IssueContext(ctx context.Context, user *users.User) (*TokenPair, error) {
+	return s.issue(ctx, user, "")
+}
+
+// Refresh exchanges the refresh token for the new tokens pair, see RefreshContext
+func (s *RefreshStore) Refresh(refreshToken string) (*TokenPair, error) {
+	return s.RefreshContext(context.Background(), refreshToken)
+}
+
+// RefreshContext exchanges the refresh token for the new tokens pair of the same family.
+// The refresh token is verified as ParseRefreshToken does and is usable once.
+// The token stays usable if the pair is not issued, e. g. if the userFn fails.
+// Returns bubucore.ErrTokenReused and revokes the tokens family if the token is used already,
+// bubucore.ErrTokenInvalid if the token is unknown or revoked.
+func (s *RefreshStore) RefreshContext(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user is resolved before the token is used, so its failures do not burn the token
+	user, err := s.userFn(ctx, claims.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+
+	var pair *TokenPair
+	if !user.IsBlocked {
+		pair, err = s.issuer.Issue(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rec, err := s.use(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsBlocked {
+		s.revokeFamily(ctx, rec.FamilyID)
+		return nil, bubucore.ErrUserBlocked
+	}
+
+	err = s.save(ctx, pair, rec.FamilyID)
+	if errors.Is(err, ErrRefreshNotFound) {
+		// the family is revoked concurrently
+		return nil, bubucore.ErrTokenInvalid
+	}
+	if err != nil {
+		if rerr := s.storage.Release(ctx, rec.TokenID); rerr != nil {
+			log.Error(logTag, "failed to release refresh token ", rec.TokenID, ": ", rerr)
+		}
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Revoke revokes the tokens family of the refresh token, see RevokeContext
+func (s *RefreshStore) Revoke(refreshToken string) error {
+	return s.RevokeContext(context.Background(), refreshToken)
+}
+
+// RevokeContext revokes the tokens family of the refresh token, e. g. on logout
+func (s *RefreshStore) RevokeContext(ctx context.Context, refreshToken string) error {
+	claims, err := ParseRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	rec, err := s.storage.Use(ctx, claims.GetTokenID())
+	if errors.Is(err, ErrRefreshNotFound) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrRefreshReplayed) {
+		return err
+	}
+	return s.storage.RevokeFamily(ctx, rec.FamilyID)
+}
+
+// RevokeUser revokes all the user's tokens families, see RevokeUserContext
+func (s *RefreshStore) RevokeUser(userID string) error {
+	return s.RevokeUserContext(context.Background(), userID)
+}
+
+// RevokeUserContext revokes all the user's tokens families, e. g. on logout from all devices or blocking
+func (s *RefreshStore) RevokeUserContext(ctx context.Context, userID string) error {
+	return s.storage.RevokeUser(ctx, userID)
+}
+
+// issue issues the tokens pair and records the refresh token in the family, the new family if empty
+func (s *RefreshStore) issue(ctx context.Context, user *users.User, familyID string) (*TokenPair, error) {
+	pair, err := s.issuer.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+	err = s.save(ctx, pair, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// save records the pair's refresh token in the family, the new family if empty
+func (s *RefreshStore) save(ctx context.Context, pair *TokenPair, familyID string) error {
+	if familyID == "" {
+		familyID = pair.RefreshClaims.GetTokenID()
+	}
+	return s.storage.Save(ctx, &RefreshRecord{
+		TokenID:   pair.RefreshClaims.GetTokenID(),
+		FamilyID:  familyID,
+		UserID:    pair.RefreshClaims.GetUserID(),
+		ExpiresAt: time.Unix(pair.RefreshClaims.ExpiresAt, 0),
+	})
+}
+
+// use marks the refresh token used, revokes its family on replay
+func (s *RefreshStore) use(ctx context.Context, claims *RefreshTokenClaims) (*RefreshRecord, error) {
+	rec, err := s.storage.Use(ctx, claims.GetTokenID())
+	switch {
+	case errors.Is(err, ErrRefreshReplayed):
+		log.Warn(logTag, "refresh token ", claims.GetTokenID(), " of the user ", rec.UserID, " is reused, revoking its family")
+		s.revokeFamily(ctx, rec.FamilyID)
+		return nil, bubucore.ErrTokenReused
+	case errors.Is(err, ErrRefreshNotFound):
+		return nil, bubucore.ErrTokenInvalid
+	case err != nil:
+		return nil, err
+	}
+	if rec.UserID != claims.GetUserID() {
+		return nil, bubucore.ErrTokenInvalid
+	}
+	return rec, nil
+}
+
+// revokeFamily revokes the tokens family logging the failure
+func (s *RefreshStore) revokeFamily(ctx context.Context, familyID string) {
+	if err := s.storage.RevokeFamily(ctx, familyID); err != nil {
+		log.Error(logTag, "failed to revoke refresh tokens family ", familyID, ": ", err)
+	}
+}
+
+// NewMemoryRefreshStorage creates new MemoryRefreshStorage instance
+func NewMemoryRefreshStorage() *MemoryRefreshStorage {
+	return &MemoryRefreshStorage{
+		records:  make(map[string]*memoryRefreshRecord),
+		families: make(map[string]map[string]struct{}),
+		users:    make(map[string]map[string]struct{}),
+		revoked:  make(map[string]time.Time),
+	}
+}
+
+// MemoryRefreshStorage is an in-memory RefreshStorage. Safe for concurrent use.
+type MemoryRefreshStorage struct {
+	mu        sync.Mutex
+	records   map[string]*memoryRefreshRecord
+	families  map[string]map[string]struct{}
+	users     map[string]map[string]struct{}
+	revoked   map[string]time.Time
+	lastSweep time.Time
+}
+
+// memoryRefreshRecord is a MemoryRefreshStorage record
+type memoryRefreshRecord struct {
+	RefreshRecord
+	used bool
+}
+
+// Save records the issued refresh token until its expiration.
+// Returns ErrRefreshNotFound if the family is revoked.
+func (m *MemoryRefreshStorage) Save(_ context.Context, rec *RefreshRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.lastSweep) >= memorySweepInterval {
+		m.sweep()
+	}
+	if _, ok := m.revoked[rec.FamilyID]; ok {
+		return ErrRefreshNotFound
+	}
+
+	m.records[rec.TokenID] = &memoryRefreshRecord{RefreshRecord: *rec}
+	if m.families[rec.FamilyID] == nil {
+		m.families[rec.FamilyID] = make(map[string]struct{})
+	}
+	m.families[rec.FamilyID][rec.TokenID] = struct{}{}
+	if m.users[rec.UserID] == nil {
+		m.users[rec.UserID] = make(map[string]struct{})
+	}
+	m.users[rec.UserID][rec.FamilyID] = struct{}{}
+	return nil
+}
+
+// Use atomically marks the refresh token used and returns its record
+func (m *MemoryRefreshStorage) Use(_ context.Context, tokenID string) (*RefreshRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.records[tokenID]
+	if !ok || !time.Now().Before(r.ExpiresAt) {
+		return nil, ErrRefreshNotFound
+	}
+	rec := r.RefreshRecord
+	if r.used {
+		return &rec, ErrRefreshReplayed
+	}
+	r.used = true
+	return &rec, nil
+}
+
+// Release marks the used refresh token unused again
+func (m *MemoryRefreshStorage) Release(_ context.Context, tokenID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.records[tokenID]; ok {
+		r.used = false
+	}
+	return nil
+}
+
+// RevokeFamily revokes all the tokens of the family
+func (m *MemoryRefreshStorage) RevokeFamily(_ context.Context, familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeFamily(familyID)
+	return nil
+}
+
+// RevokeUser revokes all the user's tokens
+func (m *MemoryRefreshStorage) RevokeUser(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for familyID := range m.users[userID] {
+		m.revokeFamily(familyID)
+	}
+	delete(m.users, userID)
+	return nil
+}
+
+// Len returns the stored records count
+func (m *MemoryRefreshStorage) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.records)
+}
+
+// revokeFamily removes the family records and marks the family revoked until its expiration,
+// the lock must be held
+func (m *MemoryRefreshStorage) revokeFamily(familyID string) {
+	for tokenID := range m.families[familyID] {
+		if r, ok := m.records[tokenID]; ok {
+			if r.ExpiresAt.After(m.revoked[familyID]) {
+				m.revoked[familyID] = r.ExpiresAt
+			}
+			if fams := m.users[r.UserID]; fams != nil {
+				delete(fams, familyID)
+				if len(fams) == 0 {
+					delete(m.users, r.UserID)
+				}
+			}
+			delete(m.records, tokenID)
+		}
+	}
+	delete(m.families, familyID)
+}
+
+// sweep removes the expired records, the lock must be held
+func (m *MemoryRefreshStorage) sweep() {
+	now := time.Now()
+	m.lastSweep = now
+	for familyID, expiresAt := range m.revoked {
+		if !now.Before(expiresAt) {
+			delete(m.revoked, familyID)
+		}
+	}
+	for tokenID, r := range m.records {
+		if now.Before(r.ExpiresAt) {
+			continue
+		}
+		delete(m.records, tokenID)
+		if fam := m.families[r.FamilyID]; fam != nil {
+			delete(fam, tokenID)
+			if len(fam) == 0 {
+				delete(m.families, r.FamilyID)
+				if fams := m.users[r.UserID]; fams != nil {
+					delete(fams, r.FamilyID)
+					if len(fams) == 0 {
+						delete(m.users, r.UserID)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package tokens
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// redisRefreshPrefix is a RedisRefreshStorage keys prefix
+const redisRefreshPrefix = "bubu_refresh:"
+
+// redisUseScript marks the token used and returns its record and whether it is used the first time
+var redisUseScript = redis.NewScript(`
+local rec = redis.call('HGET', KEYS[1], 'rec')
+if not rec then
+	return false
+end
+return {rec, redis.call('HSETNX', KEYS[1], 'used', '1')}
+`)
+
+// redisSaveScript records the token in the family unless the family is revoked
+var redisSaveScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[4]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'rec', ARGV[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[2])
+redis.call('SADD', KEYS[2], ARGV[3])
+redis.call('EXPIREAT', KEYS[2], ARGV[2])
+redis.call('SADD', KEYS[3], ARGV[4])
+redis.call('EXPIREAT', KEYS[3], ARGV[2])
+return 1
+`)
+
+// redisRevokeLua removes the family tokens and marks the family revoked until its expiration.
+// Returns the family's user ID if any of its tokens is found.
+const redisRevokeLua = `
+local function revoke(prefix, fid)
+	local fkey = prefix .. 'family:' .. fid
+	local ttl = redis.call('PTTL', fkey)
+	if ttl > 0 then
+		redis.call('SET', prefix .. 'revoked:' .. fid, '1', 'PX', ttl)
+	end
+	local uid
+	for _, tid in ipairs(redis.call('SMEMBERS', fkey)) do
+		local tkey = prefix .. 'token:' .. tid
+		if not uid then
+			local rec = redis.call('HGET', tkey, 'rec')
+			if rec then
+				uid = cjson.decode(rec)['uid']
+			end
+		end
+		redis.call('DEL', tkey)
+	end
+	redis.call('DEL', fkey)
+	return uid
+end
+`
+
+// redisRevokeFamilyScript revokes the family and removes it from the user's families
+var redisRevokeFamilyScript = redis.NewScript(redisRevokeLua + `
+local uid = revoke(ARGV[1], ARGV[2])
+if uid then
+	redis.call('SREM', ARGV[1] .. 'user:' .. uid, ARGV[2])
+end
+return 1
+`)
+
+// redisRevokeUserScript revokes all the user's families
+var redisRevokeUserScript = redis.NewScript(redisRevokeLua + `
+for _, fid in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	revoke(ARGV[1], fid)
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// NewRedisRefreshStore creates new RefreshStore instance with the RedisRefreshStorage,
+// e. g. with the app.DIGetRedis client
+func NewRedisRefreshStore(client *redis.Client, issuer *Issuer, userFn UserFn) *RefreshStore {
+	return NewRefreshStore(NewRedisRefreshStorage(client), issuer, userFn)
+}
+
+// NewRedisRefreshStorage creates new RedisRefreshStorage instance
+func NewRedisRefreshStorage(client *redis.Client) *RedisRefreshStorage {
+	return &RedisRefreshStorage{
+		client: client,
+		prefix: redisRefreshPrefix,
+	}
+}
+
+// RedisRefreshStorage is a Redis based RefreshStorage shared by the replicas.
+// Records expire with the refresh tokens; families and users are Redis sets of the tokens and families IDs.
+// Revocations are done with Lua scripts, revoked families are marked to reject the concurrent rotations.
+type RedisRefreshStorage struct {
+	client *redis.Client
+	prefix string
+}
+
+// Save records the issued refresh token until its expiration.
+// Returns ErrRefreshNotFound if the family is revoked.
+func (r *RedisRefreshStorage) Save(ctx context.Context, rec *RefreshRecord) error {
+	data, err := jsoniter.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	keys := []string{
+		r.tokenKey(rec.TokenID),
+		r.familyKey(rec.FamilyID),
+		r.userKey(rec.UserID),
+		r.revokedKey(rec.FamilyID),
+	}
+	saved, err := redisSaveScript.Run(ctx, r.client, keys, data, rec.ExpiresAt.Unix(), rec.TokenID, rec.FamilyID).Int()
+	if err != nil {
+		return err
+	}
+	if saved != 1 {
+		return ErrRefreshNotFound
+	}
+	return nil
+}
+
+// Use atomically marks the refresh token used and returns its record
+func (r *RedisRefreshStorage) Use(ctx context.Context, tokenID string) (*RefreshRecord, error) {
+	res, err := redisUseScript.Run(ctx, r.client, []string{r.tokenKey(tokenID)}).Result()
+	if err == redis.Nil {
+		return nil, ErrRefreshNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, ErrRefreshNotFound
+	}
+	data, _ := values[0].(string)
+	first, _ := values[1].(int64)
+
+	rec := &RefreshRecord{}
+	err = jsoniter.Unmarshal([]byte(data), rec)
+	if err != nil {
+		return nil, err
+	}
+	if first != 1 {
+		return rec, ErrRefreshReplayed
+	}
+	return rec, nil
+}
+
+// Release marks the used refresh token unused again
+func (r *RedisRefreshStorage) Release(ctx context.Context, tokenID string) error {
+	return r.client.HDel(ctx, r.tokenKey(tokenID), "used").Err()
+}
+
+// RevokeFamily atomically revokes all the tokens of the family
+func (r *RedisRefreshStorage) RevokeFamily(ctx context.Context, familyID string) error {
+	return redisRevokeFamilyScript.Run(ctx, r.client, []string{r.familyKey(familyID)}, r.prefix, familyID).Err()
+}
+
+// RevokeUser atomically revokes all the user's tokens
+func (r *RedisRefreshStorage) RevokeUser(ctx context.Context, userID string) error {
+	return redisRevokeUserScript.Run(ctx, r.client, []string{r.userKey(userID)}, r.prefix).Err()
+}
+
+// tokenKey returns the token record key
+func (r *RedisRefreshStorage) tokenKey(tokenID string) string {
+	return r.prefix + "token:" + tokenID
+}
+
+// familyKey returns the family tokens set key
+func (r *RedisRefreshStorage) familyKey(familyID string) string {
+	return r.prefix + "family:" + familyID
+}
+
+// revokedKey returns the revoked family marker key
+func (r *RedisRefreshStorage) revokedKey(familyID string) string {
+	return r.prefix + "revoked:" + familyID
+}
+
+// userKey returns the user families set key
+func (r *RedisRefreshStorage) userKey(userID string) string {
+	return r.prefix + "user:" + userID
+}
@@ -0,0 +1,228 @@
+package tokens_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/tokens"
+	"github.com/bubulearn/bubucore/users"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errUsersUnavailable is a userFn failure
+var errUsersUnavailable = errors.New("users service is unavailable")
+
+func TestMemoryRefreshStore(t *testing.T) {
+	testRefreshStore(t, func(issuer *tokens.Issuer, userFn tokens.UserFn) *tokens.RefreshStore {
+		return tokens.NewMemoryRefreshStore(issuer, userFn)
+	})
+}
+
+func TestRedisRefreshStore(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer func() {
+		_ = client.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("redis is not available: ", err)
+	}
+
+	testRefreshStore(t, func(issuer *tokens.Issuer, userFn tokens.UserFn) *tokens.RefreshStore {
+		return tokens.NewRedisRefreshStore(client, issuer, userFn)
+	})
+}
+
+// failingStorage is a RefreshStorage failing the next Save
+type failingStorage struct {
+	tokens.RefreshStorage
+	fail bool
+}
+
+func (s *failingStorage) Save(ctx context.Context, rec *tokens.RefreshRecord) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("storage is unavailable")
+	}
+	return s.RefreshStorage.Save(ctx, rec)
+}
+
+func TestRefreshStore_SaveFailure(t *testing.T) {
+	initial := bubucore.Opt.JWTPassword
+	bubucore.Opt.JWTPassword = []byte("test")
+	defer func() { bubucore.Opt.JWTPassword = initial }()
+
+	issuer, err := tokens.NewIssuer(tokens.IssuerOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	user := &users.User{ID: "03a4e59c-fb22-4bfa-8739-8062bcdd2005", Role: users.RoleStudent}
+	storage := &failingStorage{RefreshStorage: tokens.NewMemoryRefreshStorage()}
+	store := tokens.NewRefreshStore(storage, issuer, func(ctx context.Context, userID string) (*users.User, error) {
+		return user, nil
+	})
+
+	pair, err := store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the token is released on failure, the retry succeeds
+	storage.fail = true
+	_, err = store.Refresh(pair.RefreshToken)
+	assert.Error(t, err)
+	next, err := store.Refresh(pair.RefreshToken)
+	if assert.NoError(t, err) {
+		_, err = store.Refresh(next.RefreshToken)
+		assert.NoError(t, err)
+	}
+}
+
+// testRefreshStore tests the RefreshStore created with the create func
+func testRefreshStore(t *testing.T, create func(issuer *tokens.Issuer, userFn tokens.UserFn) *tokens.RefreshStore) {
+	initial := bubucore.Opt.JWTPassword
+	bubucore.Opt.JWTPassword = []byte("test")
+	defer func() { bubucore.Opt.JWTPassword = initial }()
+
+	issuer, err := tokens.NewIssuer(tokens.IssuerOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var mu sync.Mutex
+	var userErr error
+	user := &users.User{ID: "03a4e59c-fb22-4bfa-8739-8062bcdd2005", Role: users.RoleStudent, Name: "John"}
+	userFn := func(ctx context.Context, userID string) (*users.User, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if userErr != nil {
+			return nil, userErr
+		}
+		if userID != user.ID {
+			return nil, bubucore.ErrNotFound
+		}
+		u := *user
+		return &u, nil
+	}
+	setUser := func(fn func(u *users.User)) {
+		mu.Lock()
+		fn(user)
+		mu.Unlock()
+	}
+
+	store := create(issuer, userFn)
+
+	pair, err := store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, err := store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// failed user request keeps the token usable
+	mu.Lock()
+	userErr = errUsersUnavailable
+	mu.Unlock()
+	_, err = store.Refresh(pair.RefreshToken)
+	assert.ErrorIs(t, err, errUsersUnavailable)
+	mu.Lock()
+	userErr = nil
+	mu.Unlock()
+
+	// rotation
+	setUser(func(u *users.User) { u.Name = "John Doe" })
+	next, err := store.Refresh(pair.RefreshToken)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "John Doe", next.AccessClaims.Name)
+	assert.NotEqual(t, pair.RefreshClaims.GetTokenID(), next.RefreshClaims.GetTokenID())
+	assert.Equal(t, next.RefreshClaims.GetTokenID(), next.AccessClaims.GetRelatedTokenID())
+	assert.Equal(t, next.AccessClaims.GetTokenID(), next.RefreshClaims.GetRelatedTokenID())
+
+	// replay revokes the family
+	_, err = store.Refresh(pair.RefreshToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenReused)
+	_, err = store.Refresh(next.RefreshToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+
+	// no rotation into the revoked family
+	err = store.Storage().Save(context.Background(), &tokens.RefreshRecord{
+		TokenID:   "c3e1a1f2-59a4-4a0e-a7c5-2b51ab0e9a4c",
+		FamilyID:  pair.RefreshClaims.GetTokenID(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, tokens.ErrRefreshNotFound)
+
+	// other families are kept
+	other, err = store.Refresh(other.RefreshToken)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// concurrent refresh: a single winner, the family is revoked
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := store.Refresh(other.RefreshToken)
+			results <- err
+		}()
+	}
+	errs := []error{<-results, <-results}
+	assert.Contains(t, errs, nil)
+	assert.Contains(t, errs, bubucore.ErrTokenReused)
+
+	// logout
+	pair, err = store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, store.Revoke(pair.RefreshToken))
+	_, err = store.Refresh(pair.RefreshToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+
+	// logout from all devices
+	pair, err = store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, err = store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, store.RevokeUser(user.ID))
+	for _, p := range []*tokens.TokenPair{pair, other} {
+		_, err = store.Refresh(p.RefreshToken)
+		assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	}
+
+	// blocked user
+	pair, err = store.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	setUser(func(u *users.User) { u.IsBlocked = true })
+	_, err = store.Refresh(pair.RefreshToken)
+	assert.ErrorIs(t, err, bubucore.ErrUserBlocked)
+	setUser(func(u *users.User) { u.IsBlocked = false })
+
+	// unknown and invalid tokens
+	unknown, err := issuer.Issue(user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = store.Refresh(unknown.RefreshToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	_, err = store.Refresh(unknown.AccessToken)
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+	_, err = store.Refresh("invalid")
+	assert.ErrorIs(t, err, bubucore.ErrTokenInvalid)
+}
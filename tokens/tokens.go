@@ -1,6 +1,7 @@
 package tokens
 
 import (
+	"errors"
 	"github.com/bubulearn/bubucore"
 	"github.com/bubulearn/bubucore/i18n"
 	"github.com/bubulearn/bubucore/utils"
@@ -9,27 +10,112 @@ import (
 	"time"
 )
 
-// ParseAccessToken parses access token and returns its claims
+// ParseAccessToken parses access token and returns its claims, see ParseAccessTokenWith.
+// The token is verified with the KeyProvider set, see SetKeyProvider.
 func ParseAccessToken(tokenContent string) (*AccessTokenClaims, error) {
-	parser := &jwt.Parser{
-		ValidMethods: []string{
-			jwt.SigningMethodHS256.Alg(),
-			jwt.SigningMethodHS384.Alg(),
-			jwt.SigningMethodHS512.Alg(),
-		},
+	return ParseAccessTokenWith(tokenContent, GetKeyProvider())
+}
+
+// ParseAccessTokenWith parses access token and returns its claims.
+// The token is verified with the provider's key of its kid header if the provider is not nil,
+// with the HMAC algorithms and the bubucore.Opt.JWTPassword otherwise.
+// While the JWT password is set, the HMAC tokens are accepted with the provider too,
+// so the tokens issued before the switch to the asymmetric keys stay valid until the password is unset.
+// Returns bubucore.ErrKeysUnavailable if the provider has no keys to verify with.
+func ParseAccessTokenWith(tokenContent string, provider KeyProvider) (*AccessTokenClaims, error) {
+	claims := &AccessTokenClaims{}
+	err := parseToken(tokenContent, claims, provider)
+	if err != nil {
+		log.Warn("failed to parse JWT (access token) ", unverifiedTokenID(tokenContent), ": ", err)
+		return nil, tokenError(err)
 	}
-	parsed, err := parser.ParseWithClaims(tokenContent, &AccessTokenClaims{}, parseJWTKeyFunc)
+	return claims, nil
+}
+
+// ParseRefreshToken parses refresh token and returns its claims, see ParseRefreshTokenWith.
+// The token is verified with the KeyProvider set, see SetKeyProvider.
+func ParseRefreshToken(tokenContent string) (*RefreshTokenClaims, error) {
+	return ParseRefreshTokenWith(tokenContent, GetKeyProvider())
+}
+
+// ParseRefreshTokenWith parses refresh token and returns its claims, verifying it as ParseAccessTokenWith does
+func ParseRefreshTokenWith(tokenContent string, provider KeyProvider) (*RefreshTokenClaims, error) {
+	claims := &RefreshTokenClaims{}
+	err := parseToken(tokenContent, claims, provider)
 	if err != nil {
-		log.Warn("failed to parse JWT (access token): ", tokenContent, ": ", err)
-		return nil, bubucore.ErrTokenInvalid
+		log.Warn("failed to parse JWT (refresh token) ", unverifiedTokenID(tokenContent), ": ", err)
+		return nil, tokenError(err)
 	}
-	claims := parsed.Claims.(*AccessTokenClaims)
 	return claims, nil
 }
 
+// hmacMethods are the signing methods accepted with the JWT password
+var hmacMethods = []string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodHS384.Alg(),
+	jwt.SigningMethodHS512.Alg(),
+}
+
+// parseToken parses the token to the claims, verifying it with the provider's keys or the JWT password
+func parseToken(tokenContent string, claims jwt.Claims, provider KeyProvider) error {
+	parser := &jwt.Parser{
+		ValidMethods: hmacMethods,
+	}
+	keyFunc := parseJWTKeyFunc
+	if provider != nil {
+		parser.ValidMethods = asymmetricMethods
+		if len(bubucore.Opt.GetJWTPassword()) > 0 {
+			parser.ValidMethods = append(append([]string{}, asymmetricMethods...), hmacMethods...)
+		}
+		keyFunc = providerKeyFunc(provider)
+	}
+	_, err := parser.ParseWithClaims(tokenContent, claims, keyFunc)
+	return err
+}
+
+// unverifiedTokenID returns the token's jti for the logs, without verifying the token, so the token itself is never logged
+func unverifiedTokenID(tokenContent string) string {
+	claims := &TokenClaimsDft{}
+	_, _, err := (&jwt.Parser{}).ParseUnverified(tokenContent, claims)
+	if err != nil || claims.Id == "" {
+		return "(unknown jti)"
+	}
+	return "jti " + claims.Id
+}
+
+// tokenError maps the token parsing error to the bubucore.Error
+func tokenError(err error) error {
+	if ve, ok := err.(*jwt.ValidationError); ok && errors.Is(ve.Inner, bubucore.ErrKeysUnavailable) {
+		return bubucore.ErrKeysUnavailable
+	}
+	return bubucore.ErrTokenInvalid
+}
+
 // parseJWTKeyFunc returns JWT password key
 func parseJWTKeyFunc(_ *jwt.Token) (interface{}, error) {
-	return bubucore.Opt.JWTPassword, nil
+	return bubucore.Opt.GetJWTPassword(), nil
+}
+
+// providerKeyFunc returns func looking up the public key by the token's kid header.
+// The token's algorithm must match the key's one. HMAC tokens are verified with the JWT password.
+func providerKeyFunc(provider KeyProvider) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return parseJWTKeyFunc(token)
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New(logTag + "kid header is required")
+		}
+		key, err := provider.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New(logTag + "algorithm " + token.Method.Alg() + " does not match the key " + kid)
+		}
+		return key.Public, nil
+	}
 }
 
 // TokenClaims is token claims interface
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const logTag = "[bubucore.tracing] "
+
+const (
+	// exportInterval is a queued spans export interval
+	exportInterval = 5 * time.Second
+
+	// exportBatchSize is a queue size to export spans before the interval
+	exportBatchSize = 512
+
+	// queueMaxSize is a queue size to drop new spans at
+	queueMaxSize = 8192
+)
+
+// Exporter sends ended spans to a tracing backend
+type Exporter interface {
+	// Export sends the spans batch
+	Export(ctx context.Context, spans []SpanData) error
+
+	// Shutdown flushes and closes the Exporter
+	Shutdown(ctx context.Context) error
+}
+
+// global is the current spans processor
+var global = &processor{}
+
+// processor queues ended spans and exports them in batches in background
+type processor struct {
+	mu       sync.Mutex
+	exporter Exporter
+	queue    []SpanData
+	flush    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// SetExporter sets the Exporter to send sampled spans with and starts the background export.
+// The previous Exporter is shut down. Pass nil to disable the export.
+func SetExporter(e Exporter) {
+	_ = Shutdown(context.Background())
+
+	if e == nil {
+		return
+	}
+
+	global.mu.Lock()
+	global.exporter = e
+	global.flush = make(chan struct{}, 1)
+	global.stop = make(chan struct{})
+	global.done = make(chan struct{})
+	go global.run(global.flush, global.stop, global.done)
+	global.mu.Unlock()
+}
+
+// Flush exports the queued spans
+func Flush(ctx context.Context) error {
+	return global.export(ctx)
+}
+
+// Shutdown stops the background export, flushes the queued spans and shuts the Exporter down
+func Shutdown(ctx context.Context) error {
+	global.mu.Lock()
+	e, stop, done := global.exporter, global.stop, global.done
+	global.stop = nil
+	global.mu.Unlock()
+
+	if e == nil || stop == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	err := global.export(ctx)
+
+	global.mu.Lock()
+	global.exporter = nil
+	global.queue = nil
+	global.mu.Unlock()
+
+	if shutdownErr := e.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// enqueue queues the span to export
+func enqueue(span SpanData) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+
+	if global.exporter == nil || len(global.queue) >= queueMaxSize {
+		return
+	}
+
+	global.queue = append(global.queue, span)
+
+	if len(global.queue) >= exportBatchSize {
+		select {
+		case global.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports spans until stopped
+func (p *processor) run(flush chan struct{}, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-flush:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), exportInterval)
+		if err := p.export(ctx); err != nil {
+			log.Error(logTag, "failed to export spans: ", err)
+		}
+		cancel()
+	}
+}
+
+// export sends the queued spans to the Exporter
+func (p *processor) export(ctx context.Context) error {
+	p.mu.Lock()
+	e, spans := p.exporter, p.queue
+	p.queue = nil
+	p.mu.Unlock()
+
+	if e == nil || len(spans) == 0 {
+		return nil
+	}
+	return e.Export(ctx, spans)
+}
@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schemeFile is an export target scheme to write spans to a file
+const schemeFile = "file:"
+
+// NewExporter creates the Exporter by the target: an OTLP/HTTP collector URL,
+// e. g. http://localhost:4318/v1/traces, or a file path prefixed with `file:`.
+// The service name is set as the service.name resource attribute.
+func NewExporter(target string, service string) (Exporter, error) {
+	switch {
+	case strings.HasPrefix(target, schemeFile):
+		return NewFileExporter(strings.TrimPrefix(target, schemeFile), service)
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		return NewOTLPExporter(target, service), nil
+	default:
+		return nil, errors.New(logTag + "unsupported export target `" + target + "`")
+	}
+}
+
+// NewOTLPExporter creates OTLPExporter sending spans to the collector's traces endpoint,
+// e. g. http://localhost:4318/v1/traces
+func NewOTLPExporter(endpoint string, service string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// OTLPExporter sends spans to the OpenTelemetry collector with the OTLP/HTTP JSON protocol
+type OTLPExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+}
+
+// Export sends the spans batch
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := encodeOTLP(e.service, spans)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.New(logTag + "collector responded " + strconv.Itoa(resp.StatusCode) + ": " + string(msg))
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// Shutdown closes idle connections
+func (e *OTLPExporter) Shutdown(_ context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// NewFileExporter creates FileExporter appending spans to the file
+func NewFileExporter(path string, service string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{
+		f:       f,
+		service: service,
+	}, nil
+}
+
+// FileExporter writes spans batches to a file as OTLP JSON lines,
+// readable by the collector's otlpjsonfile receiver
+type FileExporter struct {
+	mu      sync.Mutex
+	f       *os.File
+	service string
+}
+
+// Export writes the spans batch
+func (e *FileExporter) Export(_ context.Context, spans []SpanData) error {
+	data, err := encodeOTLP(e.service, spans)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.f.Write(append(data, '\n'))
+	return err
+}
+
+// Shutdown closes the file
+func (e *FileExporter) Shutdown(_ context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.f.Close()
+}
+
+// region OTLP JSON
+
+// otlpRequest is an OTLP ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// encodeOTLP encodes spans to the OTLP JSON ExportTraceServiceRequest
+func encodeOTLP(service string, spans []SpanData) ([]byte, error) {
+	items := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		item := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status: otlpStatus{
+				Code:    s.Status,
+				Message: s.StatusMessage,
+			},
+		}
+		if s.Parent.IsValid() {
+			item.ParentSpanID = s.Parent.String()
+		}
+		items[i] = item
+	}
+
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]interface{}{"service.name": service}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "bubucore"},
+						Spans: items,
+					},
+				},
+			},
+		},
+	}
+
+	return jsoniter.Marshal(req)
+}
+
+// otlpAttributes converts attributes to the OTLP key-values sorted by key
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		res[i] = otlpKeyValue{Key: k, Value: otlpValue(attrs[k])}
+	}
+	return res
+}
+
+// otlpValue converts the attribute value to the OTLP AnyValue
+func otlpValue(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": val}
+	case bool:
+		return map[string]interface{}{"boolValue": val}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": val}
+	case error:
+		return map[string]interface{}{"stringValue": val.Error()}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+}
+
+// endregion OTLP JSON
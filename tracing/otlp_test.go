@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+
+	e, err := NewExporter("file:"+path, "test-service")
+	if !assert.NoError(t, err) {
+		return
+	}
+	SetExporter(e)
+
+	ctx, parent := StartSpan(context.Background(), "parent", SpanKindServer)
+	_, child := StartSpan(ctx, "child", SpanKindClient)
+	child.SetAttribute("http.status_code", 502)
+	child.SetAttribute("retry", true)
+	child.SetError(errors.New("bad gateway"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	assert.NoError(t, Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !assert.Len(t, lines, 1) {
+		return
+	}
+
+	req := otlpRequest{}
+	if !assert.NoError(t, jsoniter.Unmarshal([]byte(lines[0]), &req)) {
+		return
+	}
+	rs := req.ResourceSpans[0]
+	assert.Equal(t, "service.name", rs.Resource.Attributes[0].Key)
+	assert.Equal(t, "test-service", rs.Resource.Attributes[0].Value["stringValue"])
+
+	spans := rs.ScopeSpans[0].Spans
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, SpanKindClient, spans[0].Kind)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, StatusError, spans[0].Status.Code)
+	assert.Equal(t, "bad gateway", spans[0].Status.Message)
+	assert.Equal(t, "502", spans[0].Attributes[0].Value["intValue"])
+	assert.Equal(t, true, spans[0].Attributes[1].Value["boolValue"])
+	assert.Empty(t, spans[1].ParentSpanID)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	e, err := NewExporter(srv.URL+"/v1/traces", "test-service")
+	if !assert.NoError(t, err) {
+		return
+	}
+	SetExporter(e)
+
+	// not sampled traces are not exported
+	ctx := ContextWithRemote(context.Background(), SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID()})
+	_, span := StartSpan(ctx, "dropped", SpanKindServer)
+	span.End()
+
+	_, span = StartSpan(context.Background(), "exported", SpanKindServer)
+	span.End()
+
+	assert.NoError(t, Flush(context.Background()))
+	assert.Contains(t, string(body), `"name":"exported"`)
+	assert.NotContains(t, string(body), `"name":"dropped"`)
+
+	assert.NoError(t, Shutdown(context.Background()))
+
+	_, err = NewExporter("udp://localhost:4317", "test-service")
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Span kinds, as defined by OpenTelemetry
+const (
+	SpanKindInternal = SpanKind(1)
+	SpanKindServer   = SpanKind(2)
+	SpanKindClient   = SpanKind(3)
+)
+
+// Span status codes, as defined by OpenTelemetry
+const (
+	StatusUnset = StatusCode(0)
+	StatusOK    = StatusCode(1)
+	StatusError = StatusCode(2)
+)
+
+// SpanKind is a span kind
+type SpanKind int
+
+// StatusCode is a span status code
+type StatusCode int
+
+// SpanData is an ended span snapshot passed to the Exporter
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	Status        StatusCode
+	StatusMessage string
+}
+
+// StartSpan starts new span as a child of the ctx's span, or of the remote parent.
+// Without a parent, new trace is started. Call Span.End to finish and export it.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		data: SpanData{
+			Name:       name,
+			Kind:       kind,
+			Start:      time.Now(),
+			Attributes: make(map[string]interface{}),
+		},
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.data.SpanContext = SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  NewSpanID(),
+			Flags:   parent.Flags,
+		}
+		span.data.Parent = parent.SpanID
+	} else {
+		span.data.SpanContext = SpanContext{
+			TraceID: NewTraceID(),
+			SpanID:  NewSpanID(),
+			Flags:   FlagSampled,
+		}
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Span is a single operation of the trace. Safe for concurrent use.
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the Span's SpanContext
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetAttribute sets the attribute. Values of string, bool, integer and float types are supported.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+// SetStatus sets the Span's status
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	s.data.StatusMessage = msg
+}
+
+// SetError sets the error status if the err is not nil
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetStatus(StatusError, err.Error())
+	}
+}
+
+// End finishes the Span and queues it to the Exporter if the trace is sampled.
+// Subsequent calls are ignored.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	if data.SpanContext.IsSampled() {
+		enqueue(data)
+	}
+}
@@ -0,0 +1,203 @@
+// Package tracing is a minimal W3C Trace Context implementation:
+// it propagates the traceparent and request ID headers and exports spans in the OTLP JSON format
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Propagated headers
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderRequestID   = "X-Request-ID"
+)
+
+// FlagSampled is a trace flag to record and export the trace
+const FlagSampled = byte(0x01)
+
+// requestIDMaxLen limits the incoming request ID length
+const requestIDMaxLen = 128
+
+// ErrInvalidTraceparent is returned on the malformed traceparent value
+var ErrInvalidTraceparent = errors.New("[bubucore.tracing] invalid traceparent")
+
+// TraceID is a trace identifier
+type TraceID [16]byte
+
+// NewTraceID generates random TraceID
+func NewTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// IsValid checks if the TraceID is not zero
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// String returns hex-encoded TraceID
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is a span identifier
+type SpanID [8]byte
+
+// NewSpanID generates random SpanID
+func NewSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// IsValid checks if the SpanID is not zero
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// String returns hex-encoded SpanID
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies the span in the trace
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid checks if both TraceID and SpanID are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled checks if the FlagSampled is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent returns the traceparent header value, e. g. `00-<trace-id>-<span-id>-01`
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses the traceparent header value
+func ParseTraceparent(value string) (SpanContext, error) {
+	sc := SpanContext{}
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceparent
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+// NewRequestID generates random request ID
+func NewRequestID() string {
+	return NewTraceID().String()
+}
+
+// ValidRequestID checks if the incoming request ID is safe to use
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLen {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// contextKey is a tracing context keys type
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+	requestIDKey
+)
+
+// ContextWithSpan returns a copy of the ctx with the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the current span of the ctx, nil if none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemote returns a copy of the ctx with the remote parent SpanContext, e. g. extracted from the headers
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// SpanContextFromContext returns the SpanContext of the current span, or the remote one
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext(), true
+	}
+	sc, ok := ctx.Value(remoteKey).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// ContextWithRequestID returns a copy of the ctx with the request ID
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID of the ctx, empty if none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Extract reads the traceparent and request ID headers to the ctx
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, err := ParseTraceparent(header.Get(HeaderTraceparent)); err == nil {
+		ctx = ContextWithRemote(ctx, sc)
+	}
+	if id := header.Get(HeaderRequestID); ValidRequestID(id) {
+		ctx = ContextWithRequestID(ctx, id)
+	}
+	return ctx
+}
+
+// Inject sets the traceparent and request ID headers from the ctx
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		header.Set(HeaderTraceparent, sc.Traceparent())
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		header.Set(HeaderRequestID, id)
+	}
+}
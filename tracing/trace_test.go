@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		assert.True(t, sc.IsSampled())
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+	}
+
+	// future versions may have more fields
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.NoError(t, err)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+	}
+	for _, v := range invalid {
+		_, err = ParseTraceparent(v)
+		assert.ErrorIs(t, err, ErrInvalidTraceparent, v)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(HeaderRequestID, "req-1")
+
+	ctx := Extract(context.Background(), header)
+	assert.Equal(t, "req-1", RequestIDFromContext(ctx))
+
+	ctx, span := StartSpan(ctx, "op", SpanKindInternal)
+	defer span.End()
+
+	sc := span.SpanContext()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.NotEqual(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.Equal(t, "00f067aa0ba902b7", span.data.Parent.String())
+
+	out := http.Header{}
+	Inject(ctx, out)
+	assert.Equal(t, sc.Traceparent(), out.Get(HeaderTraceparent))
+	assert.Equal(t, "req-1", out.Get(HeaderRequestID))
+
+	// unsafe request IDs are ignored
+	header.Set(HeaderRequestID, "bad id\n")
+	assert.Empty(t, RequestIDFromContext(Extract(context.Background(), header)))
+}
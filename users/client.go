@@ -1,14 +1,14 @@
 package users
 
 import (
-	"bytes"
 	"context"
 	"github.com/bubulearn/bubucore"
+	"github.com/bubulearn/bubucore/httpclient"
+	"github.com/bubulearn/bubucore/metrics"
 	"github.com/go-redis/redis/v8"
 	jsoniter "github.com/json-iterator/go"
-	"io/ioutil"
 	"net/http"
-	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,11 +19,24 @@ const (
 	endpointUsersGetAll = "auth/users/all"
 )
 
+// cacheMetric counts the users cache lookups by result, see metrics.Default
+var cacheMetric = metrics.Default.Counter(
+	"bubu_users_cache_requests_total",
+	"Users service cache lookups by result, hit or miss.",
+	"result",
+)
+
 // NewClient creates new Client instance
 func NewClient(host string, token string) *Client {
 	return &Client{
 		host:  host,
 		token: token,
+		client: httpclient.New(httpclient.Options{
+			Name:    "users",
+			BaseURL: host,
+			LogTag:  logTag,
+			Auth:    httpclient.BearerAuth(token),
+		}),
 	}
 }
 
@@ -33,104 +46,88 @@ type Client struct {
 	token string
 
 	redis    *redis.Client
-	cacheTTL int
+	cacheTTL int64
 
-	_client *http.Client
+	client *httpclient.Client
+}
+
+// HTTP returns the underlying httpclient.Client
+func (c *Client) HTTP() *httpclient.Client {
+	return c.client
 }
 
 // SetRedis sets redis client to cache results with
 func (c *Client) SetRedis(client *redis.Client, ttl int) {
 	c.redis = client
-	c.cacheTTL = ttl
+	c.SetCacheTTL(ttl)
+}
+
+// SetCacheTTL sets cached results time-to-live in seconds. Safe for concurrent use.
+func (c *Client) SetCacheTTL(ttl int) {
+	atomic.StoreInt64(&c.cacheTTL, int64(ttl))
 }
 
-// GetAll returns all users
+// GetAll returns all users, see GetAllContext
 func (c *Client) GetAll() (users []*User, err error) {
-	err = c.DoRequest(http.MethodGet, endpointUsersGetAll, nil, &users)
+	return c.GetAllContext(context.Background())
+}
+
+// GetAllContext returns all users
+func (c *Client) GetAllContext(ctx context.Context) (users []*User, err error) {
+	err = c.DoRequestContext(ctx, http.MethodGet, endpointUsersGetAll, nil, &users)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, user := range users {
-		c.writeToCache(user)
+		c.writeToCache(ctx, user)
 	}
 
 	return users, nil
 }
 
-// GetUserInfo fetches user info by user ID
+// GetUserInfo fetches user info by user ID, see GetUserInfoContext
 func (c *Client) GetUserInfo(userID string) (user *User, err error) {
-	user = c.readFromCache(userID)
+	return c.GetUserInfoContext(context.Background(), userID)
+}
+
+// GetUserInfoContext fetches user info by user ID
+func (c *Client) GetUserInfoContext(ctx context.Context, userID string) (user *User, err error) {
+	user = c.readFromCache(ctx, userID)
 	if user != nil {
 		return user, nil
 	}
+	if c.redis != nil {
+		cacheMetric.Inc("miss")
+	}
 
-	err = c.DoRequest(http.MethodGet, endpointUserInfo+userID, nil, &user)
+	err = c.DoRequestContext(ctx, http.MethodGet, endpointUserInfo+userID, nil, &user)
 	if err != nil {
 		return nil, err
 	}
 
-	c.writeToCache(user)
+	c.writeToCache(ctx, user)
 
 	return user, nil
 }
 
 // Close the Client
 func (c *Client) Close() error {
-	if c._client != nil {
-		c._client.CloseIdleConnections()
-	}
-	return nil
+	return c.client.Close()
 }
 
-// DoRequest sends request to the users service and decodes response to the respData
-func (c *Client) DoRequest(method string, endpoint string, reqData interface{}, respData interface{}) (err error) {
-	err = c.checkPreconditions()
-	if err != nil {
-		return err
-	}
-
-	endpoint = "/" + strings.TrimLeft(endpoint, "/")
-
-	body := []byte("")
-	if reqData != nil {
-		body, err = jsoniter.Marshal(reqData)
-		if err != nil {
-			return err
-		}
-	}
-
-	req, err := http.NewRequest(method, c.host+endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Content-type", "application/json")
-
-	resp, err := c.client().Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+// DoRequest sends request to the users service and decodes response to the respData, see DoRequestContext
+func (c *Client) DoRequest(method string, endpoint string, reqData interface{}, respData interface{}) error {
+	return c.DoRequestContext(context.Background(), method, endpoint, reqData, respData)
+}
 
-	body, err = ioutil.ReadAll(resp.Body)
+// DoRequestContext sends request to the users service and decodes response to the respData
+func (c *Client) DoRequestContext(ctx context.Context, method string, endpoint string, reqData interface{}, respData interface{}) error {
+	err := c.checkPreconditions()
 	if err != nil {
 		return err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return bubucore.NewError(resp.StatusCode, logTag+" non-OK response: "+string(body))
-	}
-
-	err = jsoniter.Unmarshal(body, respData)
-	if err != nil {
-		return bubucore.NewError(http.StatusBadGateway, logTag+" failed to decode response: "+string(body))
-	}
-
-	return nil
+	return c.client.DoJSON(ctx, method, endpoint, reqData, respData)
 }
 
 // checkPreconditions validates if Client data is ok
@@ -144,30 +141,20 @@ func (c *Client) checkPreconditions() error {
 	return nil
 }
 
-// client returns http.Client instance
-func (c *Client) client() *http.Client {
-	if c._client == nil {
-		c._client = &http.Client{
-			Timeout: 10 * time.Second,
-		}
-	}
-	return c._client
-}
-
 // cacheKey returns cache key for the user info
 func (c *Client) cacheKey(userID string) string {
 	return "bubuusersservice:userinfo:" + userID
 }
 
 // readFromCache reads user info from the cache
-func (c *Client) readFromCache(userID string) *User {
+func (c *Client) readFromCache(ctx context.Context, userID string) *User {
 	if c.redis == nil {
 		return nil
 	}
 
 	cacheKey := c.cacheKey(userID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
 	cached, err := c.redis.Get(ctx, cacheKey).Result()
@@ -182,18 +169,20 @@ func (c *Client) readFromCache(userID string) *User {
 		return nil
 	}
 
+	cacheMetric.Inc("hit")
+
 	return user
 }
 
 // writeToCache saves user info to the cache
-func (c *Client) writeToCache(user *User) {
+func (c *Client) writeToCache(ctx context.Context, user *User) {
 	if c.redis == nil {
 		return
 	}
 
 	cacheKey := c.cacheKey(user.ID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
 	data, err := jsoniter.Marshal(user)
@@ -201,5 +190,5 @@ func (c *Client) writeToCache(user *User) {
 		return
 	}
 
-	c.redis.Set(ctx, cacheKey, data, time.Second*time.Duration(c.cacheTTL))
+	c.redis.Set(ctx, cacheKey, data, time.Second*time.Duration(atomic.LoadInt64(&c.cacheTTL)))
 }
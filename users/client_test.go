@@ -0,0 +1,51 @@
+package users
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_GetUserInfoContext(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/auth/user/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"u1","role":500}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "token")
+
+	user, err := c.GetUserInfoContext(context.Background(), "u1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "u1", user.ID)
+		assert.True(t, user.IsTeacher())
+	}
+
+	// cancelled request is not sent nor retried
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.GetUserInfoContext(ctx, "u1")
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	// the deadline interrupts the request in flight
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.GetUserInfoContext(ctx, "slow")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}